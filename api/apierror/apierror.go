@@ -0,0 +1,155 @@
+package apierror
+
+import (
+	"errors"
+	"github.com/go-playground/validator"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"go.uber.org/zap"
+	"math"
+	"strconv"
+	"strings"
+	"weather-subscriptions/internal/captcha"
+	"weather-subscriptions/internal/cities"
+	"weather-subscriptions/internal/ratelimit"
+	"weather-subscriptions/internal/state"
+	"weather-subscriptions/internal/subscriptions"
+	"weather-subscriptions/internal/suppressions"
+	"weather-subscriptions/internal/validation"
+)
+
+// Error is the body of every error response
+type Error struct {
+	Status  int          `json:"-"`
+	Code    string       `json:"code"`
+	Message string       `json:"message"`
+	Fields  []FieldError `json:"fields,omitempty"`
+	// Candidates are cities an ambiguous city name refers to
+	Candidates []cities.CityView `json:"candidates,omitempty"`
+}
+
+// FieldError describes why a request field is invalid
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func New(status int, code, message string) *Error {
+	return &Error{Status: status, Code: code, Message: message}
+}
+
+// Invalid returns validation error of the fields
+func Invalid(fields ...FieldError) *Error {
+	return &Error{
+		Status:  fiber.StatusBadRequest,
+		Code:    "validation_failed",
+		Message: "request is invalid",
+		Fields:  fields,
+	}
+}
+
+// InvalidQuery returns error of query parameters which could not be parsed, parser error is only logged
+// since it exposes request struct internals
+func InvalidQuery(err error) *Error {
+	zap.L().Info("invalid query", zap.Error(err))
+	return New(fiber.StatusBadRequest, "invalid_query", "query could not be parsed")
+}
+
+// InvalidBody returns error of a request body which could not be parsed, parser error is only logged
+// since it exposes request struct internals
+func InvalidBody(err error) *Error {
+	zap.L().Info("invalid request body", zap.Error(err))
+	return New(fiber.StatusBadRequest, "invalid_body", "request body could not be parsed")
+}
+
+// known maps sentinel errors of internal packages to responses, message is the error text
+var known = []struct {
+	err    error
+	status int
+	code   string
+}{
+	{subscriptions.ErrInvalidToken, fiber.StatusNotFound, "invalid_token"},
+	{subscriptions.ErrSubscriptionExists, fiber.StatusConflict, "subscription_exists"},
+	{cities.ErrCityNotFound, fiber.StatusNotFound, "city_not_found"},
+	{cities.ErrGeocoderUnavailable, fiber.StatusBadGateway, "geocoder_unavailable"},
+	{subscriptions.ErrUnsupportedLanguage, fiber.StatusBadRequest, "unsupported_language"},
+	{subscriptions.ErrInvalidTimezone, fiber.StatusBadRequest, "invalid_timezone"},
+	{subscriptions.ErrInvalidSnooze, fiber.StatusBadRequest, "invalid_snooze"},
+	{subscriptions.ErrNoAlertRules, fiber.StatusBadRequest, "alert_rules_required"},
+	{subscriptions.ErrInvalidAlertRule, fiber.StatusBadRequest, "invalid_alert_rule"},
+	{subscriptions.ErrEmailSuppressed, fiber.StatusUnprocessableEntity, "email_suppressed"},
+	{suppressions.ErrSuppressionNotFound, fiber.StatusNotFound, "suppression_not_found"},
+	{suppressions.ErrUnknownProvider, fiber.StatusNotFound, "unknown_provider"},
+	{suppressions.ErrInvalidPayload, fiber.StatusBadRequest, "invalid_payload"},
+	{ratelimit.ErrRateLimited, fiber.StatusTooManyRequests, "rate_limited"},
+	{captcha.ErrInvalidCaptcha, fiber.StatusBadRequest, "invalid_captcha"},
+	{captcha.ErrCaptchaUnavailable, fiber.StatusBadGateway, "captcha_unavailable"},
+	{state.ErrNotFound, fiber.StatusNotFound, "not_found"},
+}
+
+// Handler is fiber error handler, it responds with Error of err. Unknown errors are logged
+// and reported as internal errors without their details. Exceeded rate limits set Retry-After header
+func Handler(c *fiber.Ctx, err error) error {
+	response := From(err)
+	if response.Status >= fiber.StatusInternalServerError {
+		zap.L().Error("request failed", zap.String("method", c.Method()), zap.String("path", c.Path()), zap.Error(err))
+	}
+	var exceeded *ratelimit.ExceededError
+	if errors.As(err, &exceeded) {
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(exceeded.RetryAfter.Seconds()))))
+	}
+
+	return c.Status(response.Status).JSON(response)
+}
+
+// From converts err to response
+func From(err error) *Error {
+	var apiError *Error
+	if errors.As(err, &apiError) {
+		return apiError
+	}
+	var validationErrors validator.ValidationErrors
+	if errors.As(err, &validationErrors) {
+		fields := make([]FieldError, 0, len(validationErrors))
+		for _, fieldError := range validationErrors {
+			fields = append(fields, FieldError{
+				Field:   fieldName(fieldError),
+				Message: validation.Message(fieldError),
+			})
+		}
+		return Invalid(fields...)
+	}
+	var ambiguous *cities.AmbiguousError
+	if errors.As(err, &ambiguous) {
+		response := New(fiber.StatusUnprocessableEntity, "ambiguous_city", cities.ErrAmbiguousCity.Error())
+		response.Candidates = ambiguous.Candidates
+		return response
+	}
+	var fiberError *fiber.Error
+	if errors.As(err, &fiberError) {
+		return New(fiberError.Code, statusCode(fiberError.Code), fiberError.Message)
+	}
+	for _, k := range known {
+		if errors.Is(err, k.err) {
+			return New(k.status, k.code, k.err.Error())
+		}
+	}
+
+	return New(fiber.StatusInternalServerError, "internal_error", "internal server error")
+}
+
+// statusCode returns code of the status, e.g. "method_not_allowed"
+func statusCode(status int) string {
+	return strings.ReplaceAll(strings.ToLower(utils.StatusMessage(status)), " ", "_")
+}
+
+// fieldName returns path of the field in request, e.g. "rules[0].metric"
+func fieldName(fieldError validator.FieldError) string {
+	// namespace starts with request struct name
+	_, path, _ := strings.Cut(fieldError.Namespace(), ".")
+	return path
+}
@@ -0,0 +1,43 @@
+package apierror
+
+import (
+	"encoding/json"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"weather-subscriptions/internal/ratelimit"
+)
+
+func TestHandlerSetsRetryAfter(t *testing.T) {
+	tests := []struct {
+		name       string
+		retryAfter time.Duration
+		want       string
+	}{
+		{name: "whole seconds", retryAfter: 30 * time.Second, want: "30"},
+		{name: "fraction is rounded up", retryAfter: 1500 * time.Millisecond, want: "2"},
+		{name: "less than a second", retryAfter: time.Nanosecond, want: "1"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			app := fiber.New(fiber.Config{ErrorHandler: Handler})
+			app.Get("/", func(c *fiber.Ctx) error {
+				return &ratelimit.ExceededError{Scope: "ip", RetryAfter: test.retryAfter}
+			})
+
+			resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/", nil))
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			assert.Equal(t, fiber.StatusTooManyRequests, resp.StatusCode)
+			assert.Equal(t, test.want, resp.Header.Get(fiber.HeaderRetryAfter))
+			var body Error
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+			assert.Equal(t, "rate_limited", body.Code)
+		})
+	}
+}
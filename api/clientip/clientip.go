@@ -0,0 +1,99 @@
+package clientip
+
+import (
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"net/netip"
+	"strings"
+)
+
+const localsKey = "clientIP"
+
+// Resolver finds client IP of requests behind reverse proxies. Proxy header is read only from trusted
+// proxies and its addresses are taken from the right, since the client may prepend any addresses
+type Resolver struct {
+	header  string
+	trusted []netip.Prefix
+}
+
+// New returns resolver of the proxy header, trusted proxies are addresses or CIDR ranges
+func New(header string, trustedProxies []string) (*Resolver, error) {
+	resolver := &Resolver{header: header}
+	for _, proxy := range trustedProxies {
+		proxy = strings.TrimSpace(proxy)
+		if proxy == "" {
+			continue
+		}
+		prefix, err := parsePrefix(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", proxy, err)
+		}
+		resolver.trusted = append(resolver.trusted, prefix)
+	}
+
+	return resolver, nil
+}
+
+func parsePrefix(proxy string) (netip.Prefix, error) {
+	if strings.Contains(proxy, "/") {
+		return netip.ParsePrefix(proxy)
+	}
+	addr, err := netip.ParseAddr(proxy)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+
+	return netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()), nil
+}
+
+// Handler is middleware which stores client IP of the request for From
+func (r *Resolver) Handler(c *fiber.Ctx) error {
+	c.Locals(localsKey, r.IP(c))
+	return c.Next()
+}
+
+// IP returns the remote address, or when it is a trusted proxy the rightmost address of the proxy
+// header which is not a trusted proxy. A malformed address stops the search at the last trusted hop
+func (r *Resolver) IP(c *fiber.Ctx) string {
+	client, err := netip.ParseAddr(c.Context().RemoteIP().String())
+	if err != nil {
+		return c.Context().RemoteIP().String()
+	}
+	client = client.Unmap()
+	if r.header == "" || !r.trusts(client) {
+		return client.String()
+	}
+
+	hops := strings.Split(c.Get(r.header), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			break
+		}
+		client = hop.Unmap()
+		if !r.trusts(client) {
+			break
+		}
+	}
+
+	return client.String()
+}
+
+func (r *Resolver) trusts(addr netip.Addr) bool {
+	for _, prefix := range r.trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// From returns client IP stored by Handler, the remote address when the request was not handled by it
+func From(c *fiber.Ctx) string {
+	if ip, ok := c.Locals(localsKey).(string); ok {
+		return ip
+	}
+
+	return c.IP()
+}
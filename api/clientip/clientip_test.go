@@ -0,0 +1,83 @@
+package clientip
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+	"net"
+	"testing"
+)
+
+// resolve returns client IP of a request from the remote address with the proxy header
+func resolve(t *testing.T, resolver *Resolver, remote, forwarded string) string {
+	t.Helper()
+
+	var request fasthttp.Request
+	if forwarded != "" {
+		request.Header.Set(fiber.HeaderXForwardedFor, forwarded)
+	}
+	var requestCtx fasthttp.RequestCtx
+	requestCtx.Init(&request, &net.TCPAddr{IP: net.ParseIP(remote), Port: 41234}, nil)
+	app := fiber.New()
+	c := app.AcquireCtx(&requestCtx)
+	defer app.ReleaseCtx(c)
+
+	return resolver.IP(c)
+}
+
+func TestIP(t *testing.T) {
+	resolver, err := New(fiber.HeaderXForwardedFor, []string{"10.0.0.0/8", " 192.0.2.1 ", "", "2001:db8::/32"})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name      string
+		remote    string
+		forwarded string
+		want      string
+	}{
+		{name: "direct client", remote: "203.0.113.10", want: "203.0.113.10"},
+		{name: "header of untrusted remote is ignored", remote: "203.0.113.10", forwarded: "198.51.100.7", want: "203.0.113.10"},
+		{name: "trusted proxy", remote: "10.0.0.5", forwarded: "203.0.113.10", want: "203.0.113.10"},
+		{name: "trusted proxy address", remote: "192.0.2.1", forwarded: "203.0.113.10", want: "203.0.113.10"},
+		{name: "chain of trusted proxies", remote: "10.0.0.5", forwarded: "203.0.113.10, 192.0.2.1, 10.1.2.3", want: "203.0.113.10"},
+		{
+			name:      "spoofed addresses prepended by the client",
+			remote:    "10.0.0.5",
+			forwarded: "1.1.1.1, 10.9.9.9, 203.0.113.10",
+			want:      "203.0.113.10",
+		},
+		{
+			name:      "spoofed address behind untrusted hop",
+			remote:    "10.0.0.5",
+			forwarded: "10.9.9.9, 198.51.100.7, 10.1.2.3",
+			want:      "198.51.100.7",
+		},
+		{name: "trusted proxy without header", remote: "10.0.0.5", want: "10.0.0.5"},
+		{name: "every hop is trusted", remote: "10.0.0.5", forwarded: "10.1.2.3, 10.4.5.6", want: "10.1.2.3"},
+		{name: "malformed hop stops at the last trusted one", remote: "10.0.0.5", forwarded: "203.0.113.10, unknown, 10.1.2.3", want: "10.1.2.3"},
+		{name: "malformed rightmost hop", remote: "10.0.0.5", forwarded: "203.0.113.10, 203.0.113.11:8080", want: "10.0.0.5"},
+		{name: "IPv4 mapped addresses", remote: "::ffff:10.0.0.5", forwarded: "::ffff:203.0.113.10", want: "203.0.113.10"},
+		{name: "IPv6 proxy", remote: "2001:db8::1", forwarded: "2001:db8:1::2, 2a00:1450::200e", want: "2a00:1450::200e"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.want, resolve(t, resolver, test.remote, test.forwarded))
+		})
+	}
+}
+
+func TestIPWithoutHeader(t *testing.T) {
+	resolver, err := New("", []string{"10.0.0.0/8"})
+	require.NoError(t, err)
+
+	assert.Equal(t, "10.0.0.5", resolve(t, resolver, "10.0.0.5", "203.0.113.10"), "header is not read when none is configured")
+}
+
+func TestNewRejectsInvalidProxies(t *testing.T) {
+	for _, proxy := range []string{"10.0.0.0/33", "proxy.example.com", "10.0.0"} {
+		_, err := New(fiber.HeaderXForwardedFor, []string{proxy})
+		assert.Error(t, err, proxy)
+	}
+}
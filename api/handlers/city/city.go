@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"weather-subscriptions/api/apierror"
+	"weather-subscriptions/api/clientip"
+	"weather-subscriptions/internal/cities"
+	"weather-subscriptions/internal/config"
+	"weather-subscriptions/internal/integrations"
+	"weather-subscriptions/internal/ratelimit"
+	"weather-subscriptions/internal/state"
+	"weather-subscriptions/internal/validation"
+)
+
+const defaultSearchLimit = 10
+
+type CityHandler struct {
+	cities  cities.CityManager
+	limiter *ratelimit.Limiter
+}
+
+func NewCityHandler(
+	cfg *config.Config,
+	maps integrations.MapsIntegration,
+	state state.Stateful,
+	limiter *ratelimit.Limiter,
+) *CityHandler {
+	return &CityHandler{
+		cities:  cities.New(cfg, state, maps),
+		limiter: limiter,
+	}
+}
+
+// searchQuery is a city name typed so far, limit defaults to defaultSearchLimit
+type searchQuery struct {
+	Q     string `query:"q" json:"q" validate:"required,city"`
+	Limit int    `query:"limit" json:"limit" validate:"omitempty,min=1,max=20"`
+}
+
+// HandleSearch handles the GET /cities/search endpoint. Known cities are searched first, the query
+// is geocoded within search rate limit of the client when none of them matches
+func (ch *CityHandler) HandleSearch(c *fiber.Ctx) error {
+	var query searchQuery
+	err := c.QueryParser(&query)
+	if err != nil {
+		return apierror.InvalidQuery(err)
+	}
+	err = validation.Struct(&query)
+	if err != nil {
+		return err
+	}
+	if query.Limit == 0 {
+		query.Limit = defaultSearchLimit
+	}
+
+	ctx := cities.WithGuard(c.Context(), func() error {
+		return ch.limiter.AllowSearch(clientip.From(c))
+	})
+	found, err := ch.cities.Search(ctx, query.Q, query.Limit)
+	if err != nil {
+		return err
+	}
+
+	views := make([]cities.CityView, 0, len(found))
+	for _, city := range found {
+		views = append(views, cities.NewView(city))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(views)
+}
@@ -1,22 +1,34 @@
 package handlers
 
 import (
+	cityHandlers "weather-subscriptions/api/handlers/city"
 	subscriptionHandlers "weather-subscriptions/api/handlers/subscription"
+	suppressionHandlers "weather-subscriptions/api/handlers/suppression"
 	weatherHandlers "weather-subscriptions/api/handlers/weather"
+	"weather-subscriptions/internal/captcha"
 	"weather-subscriptions/internal/config"
-	"weather-subscriptions/internal/integrations/google"
-	"weather-subscriptions/internal/mail/mailer_service"
+	"weather-subscriptions/internal/integrations"
+	"weather-subscriptions/internal/ratelimit"
 	"weather-subscriptions/internal/state"
 )
 
 type RequestHandler struct {
 	WeatherHandler      *weatherHandlers.WeatherHandler
 	SubscriptionHandler *subscriptionHandlers.SubscriptionHandler
+	SuppressionHandler  *suppressionHandlers.SuppressionHandler
+	CityHandler         *cityHandlers.CityHandler
 }
 
-func New(cfg *config.Config, state state.Stateful, mailer mailer_service.MailerService) *RequestHandler {
-	googleInt := google.New(cfg)
-	weatherHandler := weatherHandlers.NewWeatherHandler(googleInt, state)
-	subscriptionHandler := subscriptionHandlers.NewSubscriptionHandler(cfg, state, mailer, googleInt)
-	return &RequestHandler{weatherHandler, subscriptionHandler}
+func New(
+	cfg *config.Config,
+	state state.Stateful,
+	maps integrations.MapsIntegration,
+	limiter *ratelimit.Limiter,
+	verifier captcha.Verifier,
+) *RequestHandler {
+	weatherHandler := weatherHandlers.NewWeatherHandler(cfg, maps, state, limiter)
+	subscriptionHandler := subscriptionHandlers.NewSubscriptionHandler(cfg, state, maps, limiter, verifier)
+	suppressionHandler := suppressionHandlers.NewSuppressionHandler(cfg, state)
+	cityHandler := cityHandlers.NewCityHandler(cfg, maps, state, limiter)
+	return &RequestHandler{weatherHandler, subscriptionHandler, suppressionHandler, cityHandler}
 }
@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/gosimple/slug"
+	"weather-subscriptions/api/apierror"
+	"weather-subscriptions/api/clientip"
+	"weather-subscriptions/internal/cities"
+	"weather-subscriptions/internal/subscriptions"
+	"weather-subscriptions/internal/templates"
+	"weather-subscriptions/internal/validation"
+)
+
+const defaultSnoozeDays = 7
+
+// HandleGetManaged handles the GET /manage/{token} endpoint, browsers get the management page
+func (sh *SubscriptionHandler) HandleGetManaged(c *fiber.Ctx) error {
+	view, err := sh.manager.ManagedSubscription(c.Params("token"))
+	if err != nil {
+		return err
+	}
+
+	return sh.sendView(c, view)
+}
+
+// HandleUpdateManaged handles the PATCH and POST /manage/{token} endpoints
+func (sh *SubscriptionHandler) HandleUpdateManaged(c *fiber.Ctx) error {
+	var request subscriptions.UpdateRequest
+	err := c.BodyParser(&request)
+	if err != nil {
+		return apierror.InvalidBody(err)
+	}
+
+	err = validation.Struct(&request)
+	if err != nil {
+		return err
+	}
+	if request.City != "" {
+		request.City = slug.Make(request.City)
+	}
+
+	// manage link holders are limited in new cities like anyone subscribing
+	ctx := cities.WithGuard(c.Context(), func() error {
+		return sh.limiter.AllowNewCity(clientip.From(c))
+	})
+	view, err := sh.manager.UpdateSubscription(ctx, c.Params("token"), request)
+	if err != nil {
+		return err
+	}
+
+	return sh.sendView(c, view)
+}
+
+// HandlePauseManaged handles the POST /manage/{token}/pause endpoint
+func (sh *SubscriptionHandler) HandlePauseManaged(c *fiber.Ctx) error {
+	view, err := sh.manager.PauseSubscription(c.Params("token"))
+	if err != nil {
+		return err
+	}
+
+	return sh.sendView(c, view)
+}
+
+// HandleResumeManaged handles the POST /manage/{token}/resume endpoint
+func (sh *SubscriptionHandler) HandleResumeManaged(c *fiber.Ctx) error {
+	view, err := sh.manager.ResumeSubscription(c.Params("token"))
+	if err != nil {
+		return err
+	}
+
+	return sh.sendView(c, view)
+}
+
+// HandleConfirmSnooze handles the GET /manage/{token}/snooze endpoint emails link, browsers get a page
+// which submits the snooze and API clients get the subscription unchanged
+func (sh *SubscriptionHandler) HandleConfirmSnooze(c *fiber.Ctx) error {
+	view, err := sh.manager.ManagedSubscription(c.Params("token"))
+	if err != nil {
+		return err
+	}
+	if !acceptsHTML(c) {
+		return c.Status(fiber.StatusOK).JSON(view)
+	}
+
+	page, err := templates.GetSnoozePage(
+		view.Language,
+		view.Units,
+		sh.cfg.FrontendURL,
+		c.Params("token"),
+		c.QueryInt("days", defaultSnoozeDays),
+	)
+	if err != nil {
+		return err
+	}
+	return c.Type("html").SendString(page)
+}
+
+// HandleSnoozeManaged handles the POST /manage/{token}/snooze endpoint. Subscription is snoozed
+// for "days" query parameter, a week by default
+func (sh *SubscriptionHandler) HandleSnoozeManaged(c *fiber.Ctx) error {
+	view, err := sh.manager.SnoozeSubscription(c.Params("token"), c.QueryInt("days", defaultSnoozeDays))
+	if err != nil {
+		return err
+	}
+
+	return sh.sendView(c, view)
+}
+
+// HandleDeleteManaged handles the DELETE and POST /manage/{token}/delete endpoints, browsers get a page
+// in subscriber language
+func (sh *SubscriptionHandler) HandleDeleteManaged(c *fiber.Ctx) error {
+	if !acceptsHTML(c) {
+		err := sh.manager.DeleteSubscription(c.Params("token"))
+		if err != nil {
+			return err
+		}
+		return c.SendStatus(fiber.StatusOK)
+	}
+
+	view, err := sh.manager.ManagedSubscription(c.Params("token"))
+	if err != nil {
+		return err
+	}
+	err = sh.manager.DeleteSubscription(c.Params("token"))
+	if err != nil {
+		return err
+	}
+
+	page, err := templates.GetSubscriptionDeletedPage(view.Language)
+	if err != nil {
+		return err
+	}
+	return c.Type("html").SendString(page)
+}
+
+// sendView renders the management page for browsers and subscription JSON for API clients
+func (sh *SubscriptionHandler) sendView(c *fiber.Ctx, view *subscriptions.SubscriptionView) error {
+	if !acceptsHTML(c) {
+		return c.Status(fiber.StatusOK).JSON(view)
+	}
+
+	page := templates.ManagePage{
+		Email:        view.Email,
+		City:         view.City,
+		Frequency:    view.Frequency,
+		Timezone:     view.Timezone,
+		DeliveryHour: view.DeliveryHour,
+		Confirmed:    view.Confirmed,
+		Paused:       view.Paused,
+		SnoozedUntil: view.SnoozedUntil,
+		Units:        view.Units,
+		Language:     view.Language,
+	}
+	body, err := templates.GetManagePage(page, sh.cfg.FrontendURL, c.Params("token"))
+	if err != nil {
+		return err
+	}
+	return c.Type("html").SendString(body)
+}
+
+func acceptsHTML(c *fiber.Ctx) bool {
+	return c.Accepts(fiber.MIMEApplicationJSON, fiber.MIMETextHTML) == fiber.MIMETextHTML
+}
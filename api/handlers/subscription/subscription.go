@@ -1,52 +1,78 @@
 package handlers
 
 import (
-	"github.com/go-playground/validator"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gosimple/slug"
+	"weather-subscriptions/api/apierror"
+	"weather-subscriptions/api/clientip"
+	"weather-subscriptions/internal/captcha"
+	"weather-subscriptions/internal/cities"
 	"weather-subscriptions/internal/config"
 	"weather-subscriptions/internal/integrations"
-	"weather-subscriptions/internal/mail/mailer_service"
+	"weather-subscriptions/internal/ratelimit"
 	"weather-subscriptions/internal/state"
 	"weather-subscriptions/internal/subscriptions"
+	"weather-subscriptions/internal/validation"
 )
 
 type SubscriptionHandler struct {
-	manager subscriptions.SubManager
+	cfg      *config.Config
+	manager  subscriptions.SubManager
+	limiter  *ratelimit.Limiter
+	verifier captcha.Verifier
 }
 
 func NewSubscriptionHandler(
 	cfg *config.Config,
 	state state.Stateful,
-	mailer mailer_service.MailerService,
 	integration integrations.MapsIntegration,
+	limiter *ratelimit.Limiter,
+	verifier captcha.Verifier,
 ) *SubscriptionHandler {
-	manager := subscriptions.New(cfg, state, mailer, integration)
+	manager := subscriptions.New(cfg, state, integration)
 	return &SubscriptionHandler{
-		manager: manager,
+		cfg:      cfg,
+		manager:  manager,
+		limiter:  limiter,
+		verifier: verifier,
 	}
 }
 
-// HandleSubscribe handles the POST /subscribe endpoint
+// HandleSubscribe handles the POST /subscribe endpoint. Requests are rate limited per client IP,
+// per email and per city which is not known yet, captcha is verified when it is configured.
+// City is given by name or by lat and lon coordinates
 func (sh *SubscriptionHandler) HandleSubscribe(c *fiber.Ctx) error {
-	var request subscriptions.SubscribeRequest
-	err := c.BodyParser(&request)
+	err := sh.limiter.AllowIP(clientip.From(c))
 	if err != nil {
 		return err
 	}
 
-	validate := validator.New()
-	err = validate.Struct(&request)
+	var request subscriptions.SubscribeRequest
+	err = c.BodyParser(&request)
+	if err != nil {
+		return apierror.InvalidBody(err)
+	}
+
+	err = validation.Struct(&request)
+	if err != nil {
+		return err
+	}
+	err = sh.verifier.Verify(c.Context(), request.CaptchaToken, clientip.From(c))
+	if err != nil {
+		return err
+	}
+	err = sh.limiter.AllowEmail(request.Email)
 	if err != nil {
 		return err
 	}
 	request.City = slug.Make(request.City)
 
-	err = sh.manager.InviteUser(c.Context(), request)
-	if err != nil && err.Error() == "user already exists" {
-		return c.SendStatus(fiber.StatusConflict)
-	} else if err != nil {
-		return c.SendStatus(fiber.StatusBadRequest)
+	ctx := cities.WithGuard(c.Context(), func() error {
+		return sh.limiter.AllowNewCity(clientip.From(c))
+	})
+	err = sh.manager.InviteUser(ctx, request)
+	if err != nil {
+		return err
 	}
 
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "confirmation email sent"})
@@ -56,29 +82,28 @@ func (sh *SubscriptionHandler) HandleSubscribe(c *fiber.Ctx) error {
 func (sh *SubscriptionHandler) HandleConfirmSubscription(c *fiber.Ctx) error {
 	token := c.Params("token")
 	if token == "" {
-		return c.SendStatus(fiber.StatusBadRequest)
+		return subscriptions.ErrInvalidToken
 	}
 
 	err := sh.manager.Subscribe(token)
-	if err != nil && err.Error() == "invalid token" {
-		return c.SendStatus(fiber.StatusNotFound)
-	} else if err != nil {
-		return c.SendStatus(fiber.StatusBadRequest)
+	if err != nil {
+		return err
 	}
 
 	return c.SendStatus(fiber.StatusOK)
 }
 
-// HandleUnsubscribe handles the POST /unsubscribe/{token} endpoint
+// HandleUnsubscribe handles the GET and POST /unsubscribe/{token} endpoints, POST is RFC 8058 one-click
+// unsubscribe mail clients send for List-Unsubscribe-Post header
 func (sh *SubscriptionHandler) HandleUnsubscribe(c *fiber.Ctx) error {
 	token := c.Params("token")
 	if token == "" {
-		return c.SendStatus(fiber.StatusBadRequest)
+		return subscriptions.ErrInvalidToken
 	}
 
 	err := sh.manager.Unsubscribe(token)
 	if err != nil {
-		return c.SendStatus(fiber.StatusNotFound)
+		return err
 	}
 
 	return c.SendStatus(fiber.StatusOK)
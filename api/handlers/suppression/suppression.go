@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"github.com/gofiber/fiber/v2"
+	"strings"
+	"weather-subscriptions/api/apierror"
+	"weather-subscriptions/internal/config"
+	"weather-subscriptions/internal/state"
+	"weather-subscriptions/internal/suppressions"
+	"weather-subscriptions/internal/validation"
+)
+
+type SuppressionHandler struct {
+	cfg     *config.Config
+	manager suppressions.SuppressionManager
+}
+
+func NewSuppressionHandler(cfg *config.Config, state state.Stateful) *SuppressionHandler {
+	return &SuppressionHandler{
+		cfg:     cfg,
+		manager: suppressions.New(state),
+	}
+}
+
+// HandleBounceWebhook handles the POST /webhooks/bounces/{provider} endpoint,
+// provider is one of "generic", "ses" or "sendgrid"
+func (sh *SuppressionHandler) HandleBounceWebhook(c *fiber.Ctx) error {
+	if !tokenMatches(sh.cfg.BounceWebhookToken, c.Query("token")) {
+		return fiber.ErrNotFound
+	}
+
+	provider := c.Params("provider")
+	events, err := suppressions.ParseWebhook(provider, c.Body())
+	if err != nil {
+		return err
+	}
+	suppressed, err := sh.manager.Ingest(provider, events)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"suppressed": suppressed})
+}
+
+// Authorize checks bearer token of admin API, the API is hidden when admin token is not configured
+func (sh *SuppressionHandler) Authorize(c *fiber.Ctx) error {
+	if sh.cfg.AdminToken == "" {
+		return fiber.ErrNotFound
+	}
+	token, found := strings.CutPrefix(c.Get(fiber.HeaderAuthorization), "Bearer ")
+	if !found || !tokenMatches(sh.cfg.AdminToken, token) {
+		return fiber.ErrUnauthorized
+	}
+
+	return c.Next()
+}
+
+// HandleListSuppressions handles the GET /admin/suppressions endpoint
+func (sh *SuppressionHandler) HandleListSuppressions(c *fiber.Ctx) error {
+	views, err := sh.manager.List()
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(views)
+}
+
+// HandleAddSuppression handles the POST /admin/suppressions endpoint
+func (sh *SuppressionHandler) HandleAddSuppression(c *fiber.Ctx) error {
+	var request suppressions.AddRequest
+	err := c.BodyParser(&request)
+	if err != nil {
+		return apierror.InvalidBody(err)
+	}
+	err = validation.Struct(&request)
+	if err != nil {
+		return err
+	}
+
+	view, err := sh.manager.Add(request)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(view)
+}
+
+// HandleRemoveSuppression handles the DELETE /admin/suppressions/{email} endpoint
+func (sh *SuppressionHandler) HandleRemoveSuppression(c *fiber.Ctx) error {
+	err := sh.manager.Remove(c.Params("email"))
+	if err != nil {
+		return err
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// tokenMatches compares tokens in constant time, nothing matches an empty expected token
+func tokenMatches(expected, actual string) bool {
+	return expected != "" && subtle.ConstantTimeCompare([]byte(expected), []byte(actual)) == 1
+}
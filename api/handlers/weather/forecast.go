@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+	"time"
+	"weather-subscriptions/api/apierror"
+	"weather-subscriptions/internal/cities"
+	"weather-subscriptions/internal/db/models"
+	"weather-subscriptions/internal/integrations"
+	"weather-subscriptions/internal/state"
+)
+
+const (
+	defaultForecastDays = 3
+	forecastLifetime    = time.Hour
+)
+
+// GetForecast handles the GET /forecast endpoint. Stored forecast is returned while it is recent,
+// otherwise the longest supported forecast is fetched, so it serves any number of days later
+func (wh *WeatherHandler) GetForecast(c *fiber.Ctx) error {
+	days := c.QueryInt("days", defaultForecastDays)
+	if days < 1 || days > integrations.MaxForecastDays {
+		return apierror.Invalid(apierror.FieldError{Field: "days", Message: "days must be between 1 and 10"})
+	}
+	preferences, err := queryPreferences(c)
+	if err != nil {
+		return err
+	}
+
+	city, err := wh.queryCity(c)
+	if err != nil {
+		return err
+	}
+
+	forecast, err := wh.state.GetForecast(city.ID, preferences.Language)
+	if err != nil && !errors.Is(err, state.ErrNotFound) {
+		return err
+	}
+	if forecast == nil || forecast.Time.Before(time.Now().Add(-forecastLifetime)) {
+		ctx := integrations.WithPreferences(c.Context(), preferences)
+		forecast, err = wh.googleInt.GetForecast(ctx, city, integrations.MaxForecastDays)
+		if err != nil {
+			zap.L().Warn("failed to get forecast", zap.String("city", city.Name), zap.Error(err))
+			return errWeatherUnavailable
+		}
+		err = wh.state.SaveForecast(forecast)
+		if err != nil {
+			return err
+		}
+	}
+
+	response := forecastResponse(forecast.InUnits(preferences.Units), days, cityLocation(city))
+	response["city"] = cities.NewView(city)
+
+	return c.Status(fiber.StatusOK).JSON(response)
+}
+
+// forecastResponse returns days starting from today in the city and hours starting from the current one
+func forecastResponse(forecast *models.Forecast, days int, location *time.Location) fiber.Map {
+	now := time.Now().In(location)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	forecastDays := make([]fiber.Map, 0, days)
+	for _, day := range forecast.Days {
+		if day.Date.Before(today) {
+			continue
+		}
+		if len(forecastDays) == days {
+			break
+		}
+		forecastDays = append(forecastDays, fiber.Map{
+			"date":                     day.Date.Format(time.DateOnly),
+			"maxTemperature":           day.MaxTemperature,
+			"minTemperature":           day.MinTemperature,
+			"precipitationProbability": day.PrecipitationProbability,
+			"description":              day.Description,
+		})
+	}
+
+	currentHour := time.Now().Truncate(time.Hour)
+	forecastHours := make([]fiber.Map, 0, len(forecast.Hours))
+	for _, hour := range forecast.Hours {
+		if hour.Time.Before(currentHour) {
+			continue
+		}
+		forecastHours = append(forecastHours, fiber.Map{
+			"time":                     hour.Time,
+			"temperature":              hour.Temperature,
+			"humidity":                 hour.Humidity,
+			"precipitationProbability": hour.PrecipitationProbability,
+			"description":              hour.Description,
+		})
+	}
+
+	return fiber.Map{
+		"units":    forecast.UnitsSystem(),
+		"language": forecast.Language,
+		"days":     forecastDays,
+		"hours":    forecastHours,
+	}
+}
+
+func cityLocation(city *models.City) *time.Location {
+	location, err := time.LoadLocation(city.TimeZone)
+	if err != nil {
+		return time.UTC
+	}
+
+	return location
+}
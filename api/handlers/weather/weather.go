@@ -1,56 +1,152 @@
 package handlers
 
 import (
-	"errors"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gosimple/slug"
-	"gorm.io/gorm"
+	"go.uber.org/zap"
+	"golang.org/x/text/language"
+	"weather-subscriptions/api/apierror"
+	"weather-subscriptions/api/clientip"
+	"weather-subscriptions/internal/cities"
+	"weather-subscriptions/internal/config"
+	"weather-subscriptions/internal/db/models"
 	"weather-subscriptions/internal/integrations"
+	"weather-subscriptions/internal/ratelimit"
 	"weather-subscriptions/internal/state"
+	"weather-subscriptions/internal/validation"
 )
 
+var errWeatherUnavailable = apierror.New(fiber.StatusBadGateway, "weather_unavailable", "weather provider is unavailable")
+
 type WeatherHandler struct {
 	googleInt integrations.MapsIntegration
 	state     state.Stateful
+	cities    cities.CityManager
+	limiter   *ratelimit.Limiter
+}
+
+func NewWeatherHandler(
+	cfg *config.Config,
+	googleInt integrations.MapsIntegration,
+	state state.Stateful,
+	limiter *ratelimit.Limiter,
+) *WeatherHandler {
+	return &WeatherHandler{
+		googleInt: googleInt,
+		state:     state,
+		cities:    cities.New(cfg, state, googleInt),
+		limiter:   limiter,
+	}
 }
 
-func NewWeatherHandler(googleInt integrations.MapsIntegration, state state.Stateful) *WeatherHandler {
-	return &WeatherHandler{googleInt: googleInt, state: state}
+// cityQuery is city of weather endpoints given by name or by coordinates, which take precedence
+type cityQuery struct {
+	City string   `query:"city" json:"city" validate:"required_without=Lat,omitempty,city"`
+	Lat  *float64 `query:"lat" json:"lat" validate:"required_with=Lon,lat"`
+	Lon  *float64 `query:"lon" json:"lon" validate:"required_with=Lat,lon"`
 }
 
 func (wh *WeatherHandler) GetWeather(c *fiber.Ctx) error {
-	cityName := c.Query("city")
-	if cityName == "" {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "city name is required"})
+	preferences, err := queryPreferences(c)
+	if err != nil {
+		return err
 	}
-	cityName = slug.Make(cityName)
 
-	city, err := wh.state.GetCity(cityName)
-	if err != nil && errors.Is(gorm.ErrRecordNotFound, err) {
-		city, err = wh.googleInt.GetCity(c.Context(), cityName)
-		if err != nil {
-			return c.SendStatus(fiber.StatusNotFound)
-		}
-		err = wh.state.SaveCity(city)
-		if err != nil {
-			return c.SendStatus(fiber.StatusBadRequest)
-		}
-	} else if err != nil {
-		return c.SendStatus(fiber.StatusBadRequest)
+	city, err := wh.queryCity(c)
+	if err != nil {
+		return err
 	}
 
-	weather, err := wh.googleInt.GetWeather(c.Context(), city)
+	weather, err := wh.googleInt.GetWeather(integrations.WithPreferences(c.Context(), preferences), city)
 	if err != nil {
-		return c.SendStatus(fiber.StatusBadRequest)
+		zap.L().Warn("failed to get weather", zap.String("city", city.Name), zap.Error(err))
+		return errWeatherUnavailable
 	}
 	err = wh.state.SaveWeather(weather)
 	if err != nil {
-		return c.SendStatus(fiber.StatusBadRequest)
+		return err
+	}
+
+	response := weatherResponse(weather.InUnits(preferences.Units))
+	response["city"] = cities.NewView(city)
+
+	return c.Status(fiber.StatusOK).JSON(response)
+}
+
+// queryPreferences returns units and language requested by "units" and "lang" query parameters,
+// metric units in default language are used when they are omitted
+func queryPreferences(c *fiber.Ctx) (integrations.Preferences, error) {
+	preferences := integrations.Preferences{
+		Units:    models.Units(c.Query("units", string(models.Metric))),
+		Language: c.Query("lang", models.DefaultLanguage),
+	}
+	if preferences.Units != models.Metric && preferences.Units != models.Imperial {
+		return preferences, apierror.Invalid(apierror.FieldError{Field: "units", Message: "units must be metric or imperial"})
+	}
+	tag, err := language.Parse(preferences.Language)
+	if err != nil {
+		return preferences, apierror.Invalid(apierror.FieldError{Field: "lang", Message: "invalid language"})
 	}
+	preferences.Language = tag.String()
 
-	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+	return preferences, nil
+}
+
+func weatherResponse(weather *models.Weather) fiber.Map {
+	response := fiber.Map{
+		"units":       weather.UnitsSystem(),
+		"language":    weather.Language,
 		"temperature": weather.Temperature,
 		"humidity":    weather.Humidity,
 		"description": weather.Description,
+		"wind": fiber.Map{
+			"speed":     weather.WindSpeed,
+			"gust":      weather.WindGust,
+			"direction": weather.WindDirection,
+			"cardinal":  weather.WindCardinal,
+		},
+		"precipitation": fiber.Map{
+			"probability": weather.PrecipitationProbability,
+			"type":        weather.PrecipitationType,
+			"amount":      weather.Precipitation,
+		},
+		"thunderstormProbability": weather.ThunderstormProbability,
+		"uvIndex":                 weather.UVIndex,
+		"pressure":                weather.Pressure,
+		"visibility":              weather.Visibility,
+		"cloudCover":              weather.CloudCover,
+	}
+	if weather.History.Available {
+		response["history"] = fiber.Map{
+			"temperatureChange": weather.History.TemperatureChange,
+			"maxTemperature":    weather.History.MaxTemperature,
+			"minTemperature":    weather.History.MinTemperature,
+			"precipitation":     weather.History.Precipitation,
+		}
+	}
+
+	return response
+}
+
+// queryCity returns city of "city" query parameter or of "lat" and "lon" coordinates, unknown city
+// is geocoded within new city rate limit of the client
+func (wh *WeatherHandler) queryCity(c *fiber.Ctx) (*models.City, error) {
+	var query cityQuery
+	err := c.QueryParser(&query)
+	if err != nil {
+		return nil, apierror.InvalidQuery(err)
+	}
+	err = validation.Struct(&query)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := cities.WithGuard(c.Context(), func() error {
+		return wh.limiter.AllowNewCity(clientip.From(c))
 	})
+	if query.Lat != nil && query.Lon != nil {
+		return wh.cities.FindAt(ctx, *query.Lat, *query.Lon)
+	}
+
+	return wh.cities.Find(ctx, slug.Make(query.City))
 }
@@ -3,8 +3,10 @@ package routes
 import (
 	"github.com/gofiber/fiber/v2"
 	"weather-subscriptions/api/handlers"
+	"weather-subscriptions/internal/captcha"
 	"weather-subscriptions/internal/config"
-	"weather-subscriptions/internal/mail/mailer_service"
+	"weather-subscriptions/internal/integrations"
+	"weather-subscriptions/internal/ratelimit"
 	"weather-subscriptions/internal/state"
 )
 
@@ -12,14 +14,38 @@ type Routes struct {
 	handler *handlers.RequestHandler
 }
 
-func New(cfg *config.Config, state state.Stateful, mailer mailer_service.MailerService) *Routes {
-	handler := handlers.New(cfg, state, mailer)
+func New(
+	cfg *config.Config,
+	state state.Stateful,
+	maps integrations.MapsIntegration,
+	limiter *ratelimit.Limiter,
+	verifier captcha.Verifier,
+) *Routes {
+	handler := handlers.New(cfg, state, maps, limiter, verifier)
 	return &Routes{handler}
 }
 
 func (r *Routes) Setup(app *fiber.App) {
 	app.Get("/weather", r.handler.WeatherHandler.GetWeather)
+	app.Get("/forecast", r.handler.WeatherHandler.GetForecast)
+	app.Get("/cities/search", r.handler.CityHandler.HandleSearch)
 	app.Post("/subscribe", r.handler.SubscriptionHandler.HandleSubscribe)
 	app.Get("/confirm/:token", r.handler.SubscriptionHandler.HandleConfirmSubscription)
 	app.Get("/unsubscribe/:token", r.handler.SubscriptionHandler.HandleUnsubscribe)
+	app.Post("/unsubscribe/:token", r.handler.SubscriptionHandler.HandleUnsubscribe)
+	app.Get("/manage/:token", r.handler.SubscriptionHandler.HandleGetManaged)
+	app.Patch("/manage/:token", r.handler.SubscriptionHandler.HandleUpdateManaged)
+	app.Post("/manage/:token", r.handler.SubscriptionHandler.HandleUpdateManaged)
+	app.Post("/manage/:token/pause", r.handler.SubscriptionHandler.HandlePauseManaged)
+	app.Post("/manage/:token/resume", r.handler.SubscriptionHandler.HandleResumeManaged)
+	app.Get("/manage/:token/snooze", r.handler.SubscriptionHandler.HandleConfirmSnooze)
+	app.Post("/manage/:token/snooze", r.handler.SubscriptionHandler.HandleSnoozeManaged)
+	app.Delete("/manage/:token", r.handler.SubscriptionHandler.HandleDeleteManaged)
+	app.Post("/manage/:token/delete", r.handler.SubscriptionHandler.HandleDeleteManaged)
+	app.Post("/webhooks/bounces/:provider", r.handler.SuppressionHandler.HandleBounceWebhook)
+
+	admin := app.Group("/admin", r.handler.SuppressionHandler.Authorize)
+	admin.Get("/suppressions", r.handler.SuppressionHandler.HandleListSuppressions)
+	admin.Post("/suppressions", r.handler.SuppressionHandler.HandleAddSuppression)
+	admin.Delete("/suppressions/:email", r.handler.SuppressionHandler.HandleRemoveSuppression)
 }
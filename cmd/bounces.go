@@ -0,0 +1,45 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"weather-subscriptions/internal/config"
+	"weather-subscriptions/internal/db"
+	"weather-subscriptions/internal/state"
+	"weather-subscriptions/internal/suppressions"
+)
+
+const bouncesUsage = "usage: bounces <mbox file>..."
+
+// runBounces handles "bounces" subcommand, it suppresses addresses of DSN and feedback reports found in mbox files
+func runBounces(cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		return errors.New(bouncesUsage)
+	}
+
+	database, err := db.Open(cfg)
+	if err != nil {
+		return err
+	}
+	manager := suppressions.New(state.NewState(cfg, database))
+
+	for _, path := range args {
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		events, err := suppressions.ParseMbox(file)
+		_ = file.Close()
+		if err != nil {
+			return fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+		suppressed, err := manager.Ingest("mbox", events)
+		if err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintf(os.Stdout, "%s\t%d reports\t%d suppressed\n", path, len(events), suppressed)
+	}
+
+	return nil
+}
@@ -5,13 +5,24 @@ import (
 	"fmt"
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"go.uber.org/zap"
+	"os"
 	"os/signal"
 	"syscall"
 	"time"
+	_ "time/tzdata"
+	"weather-subscriptions/api/apierror"
+	"weather-subscriptions/api/clientip"
 	"weather-subscriptions/api/routes"
+	"weather-subscriptions/internal/captcha"
+	"weather-subscriptions/internal/integrations"
+	"weather-subscriptions/internal/integrations/providers"
+	"weather-subscriptions/internal/jobs"
 	"weather-subscriptions/internal/mail"
 	"weather-subscriptions/internal/mail/mailer_service"
+	"weather-subscriptions/internal/mail/outbox"
+	"weather-subscriptions/internal/ratelimit"
 	"weather-subscriptions/internal/state"
+	"weather-subscriptions/internal/templates"
 
 	"github.com/go-co-op/gocron"
 	fiber "github.com/gofiber/fiber/v2"
@@ -19,6 +30,9 @@ import (
 	"weather-subscriptions/internal/db"
 )
 
+// jobCron checks scheduled jobs every minute, each of them runs once per slot of its interval
+const jobCron = "* * * * *"
+
 var (
 	webApp *fiber.App
 	appCtx context.Context
@@ -36,18 +50,66 @@ func main() {
 		panic(fmt.Sprintf("failed to read config: %v", err))
 	}
 
-	mailerService := mailer_service.New(cfg)
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err = runMigrate(cfg, os.Args[2:]); err != nil {
+			zap.L().Fatal("failed to migrate", zap.Error(err))
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "bounces" {
+		if err = runBounces(cfg, os.Args[2:]); err != nil {
+			zap.L().Fatal("failed to import bounces", zap.Error(err))
+		}
+		return
+	}
+
+	if cfg.ManageTokenSecret == "" {
+		panic("MANAGE_TOKEN_SECRET is required to sign subscription management tokens")
+	}
+	if cfg.TemplatesDir != "" {
+		err = templates.Load(cfg.TemplatesDir)
+		if err != nil {
+			panic(fmt.Sprintf("failed to load templates: %v", err))
+		}
+	}
+
+	mailerService, err := mailer_service.New(cfg)
+	if err != nil {
+		panic(fmt.Sprintf("failed to create mailer: %v", err))
+	}
+
+	maps, err := providers.New(cfg)
+	if err != nil {
+		panic(fmt.Sprintf("failed to create weather integration: %v", err))
+	}
 
 	database, err := db.Connect(cfg)
 	if err != nil {
 		panic(fmt.Sprintf("failed to connect to database: %v", err))
 	}
-	set := state.NewState(database)
+	set := state.NewState(cfg, database)
 
-	scheduler := createScheduler(cfg, set, mailerService)
+	limiter, err := ratelimit.New(cfg, set)
+	if err != nil {
+		panic(fmt.Sprintf("failed to create rate limiter: %v", err))
+	}
+	verifier, err := captcha.New(cfg)
+	if err != nil {
+		panic(fmt.Sprintf("failed to create captcha verifier: %v", err))
+	}
+	resolver, err := clientip.New(cfg.ProxyHeader, cfg.TrustedProxies)
+	if err != nil {
+		panic(fmt.Sprintf("failed to create client IP resolver: %v", err))
+	}
+	if cfg.ProxyHeader != "" && len(cfg.TrustedProxies) == 0 {
+		zap.L().Warn("PROXY_HEADER is ignored until TRUSTED_PROXIES lists the reverse proxies")
+	}
+
+	scheduler := createScheduler(cfg, set, maps)
 
 	scheduler.StartAsync()
-	go createWebserver(cfg, set, mailerService)
+	go outbox.NewDispatcher(cfg, set, mailerService).Run(appCtx)
+	go createWebserver(cfg, set, maps, limiter, verifier, resolver)
 
 	for range appCtx.Done() {
 		_ = webApp.ShutdownWithContext(appCtx)
@@ -55,29 +117,49 @@ func main() {
 	}
 }
 
-func createWebserver(cfg *config.Config, set state.Stateful, mailer mailer_service.MailerService) {
-	webApp = fiber.New()
+func createWebserver(
+	cfg *config.Config,
+	set state.Stateful,
+	maps integrations.MapsIntegration,
+	limiter *ratelimit.Limiter,
+	verifier captcha.Verifier,
+	resolver *clientip.Resolver,
+) {
+	webApp = fiber.New(fiber.Config{
+		ErrorHandler: apierror.Handler,
+	})
+
+	// client IP is the rightmost address of the proxy header which is not a trusted proxy
+	webApp.Use(resolver.Handler)
 
 	webApp.Use(cors.New(cors.Config{
 		AllowOrigins: "*",
 	}))
 
-	routes.New(cfg, set, mailer).Setup(webApp)
+	routes.New(cfg, set, maps, limiter, verifier).Setup(webApp)
 	if err := webApp.Listen(":" + cfg.Port); err != nil {
 		zap.L().Error("failed to start server: %v", zap.Error(err))
 	}
 }
 
-func createScheduler(cfg *config.Config, state state.Stateful, mailer mailer_service.MailerService) *gocron.Scheduler {
-	mailManager := mail.New(appCtx, cfg, state, mailer)
+func createScheduler(cfg *config.Config, state state.Stateful, maps integrations.MapsIntegration) *gocron.Scheduler {
+	mailManager := mail.New(appCtx, cfg, state, maps)
+	locker := jobs.NewLocker(state, cfg.JobLease)
 	scheduler := gocron.NewScheduler(time.UTC)
 
-	_, err := scheduler.Every(1).Hour().Do(mailManager.SendHourly)
+	// jobs are checked every minute and run once per slot aligned to their interval, so every replica
+	// computes the same slot and a slot left unfinished by a stopped replica is taken over after its lease
+	_, err := scheduler.Cron(jobCron).Do(locker.Once("hourly", time.Hour, mailManager.SendHourly))
+	if err != nil {
+		zap.L().Error("failed to start cron: %v", zap.Error(err))
+	}
+
+	_, err = scheduler.Cron(jobCron).Do(locker.Once("alerts", time.Hour, mailManager.SendAlerts))
 	if err != nil {
 		zap.L().Error("failed to start cron: %v", zap.Error(err))
 	}
 
-	_, err = scheduler.Every(1).Day().At("12:00").Do(mailManager.SendDaily)
+	_, err = scheduler.Cron(jobCron).Do(locker.Once("daily", mail.DailyCheckInterval, mailManager.SendDaily))
 	if err != nil {
 		zap.L().Error("failed to start cron: %v", zap.Error(err))
 	}
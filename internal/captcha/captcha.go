@@ -0,0 +1,110 @@
+package captcha
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"github.com/go-resty/resty/v2"
+	"weather-subscriptions/internal/config"
+)
+
+const (
+	HCaptcha  = "hcaptcha"
+	Turnstile = "turnstile"
+	ReCaptcha = "recaptcha"
+	Stub      = "stub"
+)
+
+var (
+	ErrInvalidCaptcha     = errors.New("captcha is missing or invalid")
+	ErrCaptchaUnavailable = errors.New("captcha could not be verified")
+)
+
+// verifyURLs are siteverify endpoints of the providers, they share request and response format
+var verifyURLs = map[string]string{
+	HCaptcha:  "https://api.hcaptcha.com/siteverify",
+	Turnstile: "https://challenges.cloudflare.com/turnstile/v0/siteverify",
+	ReCaptcha: "https://www.google.com/recaptcha/api/siteverify",
+}
+
+// Verifier checks captcha token solved by the client, ErrInvalidCaptcha is returned when it is not solved
+type Verifier interface {
+	Verify(ctx context.Context, token, remoteIP string) error
+}
+
+// New creates verifier selected by CAPTCHA_PROVIDER config key, every token is accepted when it is empty
+func New(cfg *config.Config) (Verifier, error) {
+	switch cfg.Captcha.Provider {
+	case "":
+		return disabled{}, nil
+	case Stub:
+		return &StubVerifier{Token: cfg.Captcha.StubToken}, nil
+	}
+	url, ok := verifyURLs[cfg.Captcha.Provider]
+	if !ok {
+		return nil, fmt.Errorf("unknown captcha provider: '%s'", cfg.Captcha.Provider)
+	}
+	if cfg.Captcha.VerifyURL != "" {
+		url = cfg.Captcha.VerifyURL
+	}
+
+	return &SiteVerifier{URL: url, secret: cfg.Captcha.Secret, client: resty.New()}, nil
+}
+
+type disabled struct{}
+
+func (disabled) Verify(context.Context, string, string) error { return nil }
+
+// StubVerifier accepts a fixed token, it is meant for local development and tests
+type StubVerifier struct {
+	Token string
+}
+
+func (v *StubVerifier) Verify(_ context.Context, token, _ string) error {
+	if token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(v.Token)) != 1 {
+		return ErrInvalidCaptcha
+	}
+
+	return nil
+}
+
+// SiteVerifier verifies tokens with siteverify endpoint of hCaptcha, Turnstile or reCAPTCHA
+type SiteVerifier struct {
+	URL    string
+	secret string
+	client *resty.Client
+}
+
+type siteverifyResponse struct {
+	Success    bool     `json:"success"`
+	ErrorCodes []string `json:"error-codes"`
+}
+
+func (v *SiteVerifier) Verify(ctx context.Context, token, remoteIP string) error {
+	if token == "" {
+		return ErrInvalidCaptcha
+	}
+
+	var result siteverifyResponse
+	resp, err := v.client.R().
+		SetContext(ctx).
+		SetFormData(map[string]string{
+			"secret":   v.secret,
+			"response": token,
+			"remoteip": remoteIP,
+		}).
+		SetResult(&result).
+		Post(v.URL)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrCaptchaUnavailable, err)
+	}
+	if !resp.IsSuccess() {
+		return fmt.Errorf("%w: siteverify responded with %s", ErrCaptchaUnavailable, resp.Status())
+	}
+	if !result.Success {
+		return ErrInvalidCaptcha
+	}
+
+	return nil
+}
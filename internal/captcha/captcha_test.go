@@ -0,0 +1,117 @@
+package captcha
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"weather-subscriptions/internal/config"
+)
+
+// newTestVerifier returns verifier of a siteverify endpoint answering with status and body,
+// form of the last request is stored in form
+func newTestVerifier(t *testing.T, status int, body string, form *map[string]string) Verifier {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("parse form: %v", err)
+		}
+		*form = map[string]string{
+			"secret":   r.PostForm.Get("secret"),
+			"response": r.PostForm.Get("response"),
+			"remoteip": r.PostForm.Get("remoteip"),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+
+	cfg := &config.Config{}
+	cfg.Captcha.Provider = Turnstile
+	cfg.Captcha.Secret = "secret"
+	cfg.Captcha.VerifyURL = server.URL
+	verifier, err := New(cfg)
+	require.NoError(t, err)
+
+	return verifier
+}
+
+func TestSiteVerifier(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   error
+	}{
+		{name: "solved", status: http.StatusOK, body: `{"success": true}`},
+		{name: "not solved", status: http.StatusOK, body: `{"success": false, "error-codes": ["invalid-input-response"]}`, want: ErrInvalidCaptcha},
+		{name: "provider failure", status: http.StatusInternalServerError, body: `{}`, want: ErrCaptchaUnavailable},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var form map[string]string
+			verifier := newTestVerifier(t, test.status, test.body, &form)
+
+			err := verifier.Verify(context.Background(), "token", "203.0.113.10")
+
+			if test.want == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, test.want)
+			}
+			assert.Equal(t, map[string]string{"secret": "secret", "response": "token", "remoteip": "203.0.113.10"}, form)
+		})
+	}
+}
+
+func TestSiteVerifierRejectsEmptyToken(t *testing.T) {
+	var form map[string]string
+	verifier := newTestVerifier(t, http.StatusOK, `{"success": true}`, &form)
+
+	err := verifier.Verify(context.Background(), "", "203.0.113.10")
+
+	assert.ErrorIs(t, err, ErrInvalidCaptcha)
+	assert.Nil(t, form, "empty token is not sent to the provider")
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name      string
+		provider  string
+		token     string
+		wantError error
+	}{
+		{name: "disabled accepts every token", provider: "", token: ""},
+		{name: "stub accepts its token", provider: Stub, token: "stub-token"},
+		{name: "stub rejects other tokens", provider: Stub, token: "other", wantError: ErrInvalidCaptcha},
+		{name: "stub rejects empty token", provider: Stub, token: "", wantError: ErrInvalidCaptcha},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := &config.Config{}
+			cfg.Captcha.Provider = test.provider
+			cfg.Captcha.StubToken = "stub-token"
+			verifier, err := New(cfg)
+			require.NoError(t, err)
+
+			err = verifier.Verify(context.Background(), test.token, "203.0.113.10")
+
+			if test.wantError == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, test.wantError)
+			}
+		})
+	}
+
+	cfg := &config.Config{}
+	cfg.Captcha.Provider = "unknown"
+	_, err := New(cfg)
+	assert.Error(t, err)
+}
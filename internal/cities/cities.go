@@ -0,0 +1,357 @@
+package cities
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/gosimple/slug"
+	"go.uber.org/zap"
+	"golang.org/x/sync/singleflight"
+	"strings"
+	"weather-subscriptions/internal/config"
+	"weather-subscriptions/internal/db/models"
+	"weather-subscriptions/internal/integrations"
+	"weather-subscriptions/internal/state"
+)
+
+var (
+	// ErrCityNotFound is returned when a city could not be geocoded
+	ErrCityNotFound = errors.New("city not found")
+	// ErrAmbiguousCity is matched by AmbiguousError
+	ErrAmbiguousCity = errors.New("city name is ambiguous, choose one of the candidates")
+	// ErrGeocoderUnavailable is returned by search when the geocoder failed for another reason than
+	// the place not being found
+	ErrGeocoderUnavailable = errors.New("geocoding provider is unavailable")
+)
+
+// AmbiguousError is returned when a name refers to several places, each candidate is found by its
+// name or coordinates
+type AmbiguousError struct {
+	Name       string
+	Candidates []CityView
+}
+
+func (e *AmbiguousError) Error() string {
+	return fmt.Sprintf("city name %q refers to %d places", e.Name, len(e.Candidates))
+}
+
+func (e *AmbiguousError) Is(target error) bool {
+	return target == ErrAmbiguousCity
+}
+
+// CityView is a city as it is shown to users
+type CityView struct {
+	Name          string  `json:"name"`
+	FormattedName string  `json:"formattedName"`
+	Region        string  `json:"region,omitempty"`
+	Country       string  `json:"country,omitempty"`
+	Latitude      float64 `json:"lat"`
+	Longitude     float64 `json:"lon"`
+	TimeZone      string  `json:"timezone,omitempty"`
+}
+
+func NewView(city *models.City) CityView {
+	return CityView{
+		Name:          city.Name,
+		FormattedName: city.DisplayName(),
+		Region:        city.Region,
+		Country:       city.Country,
+		Latitude:      city.Latitude,
+		Longitude:     city.Longitude,
+		TimeZone:      city.TimeZone,
+	}
+}
+
+type CityManager interface {
+	// Find returns city of the slug name or of its alias. Unknown name is geocoded, a single place it
+	// refers to is saved with the name as its alias, several places are saved and returned
+	// as AmbiguousError candidates, they are kept in memory for the name, so it is not geocoded again
+	Find(ctx context.Context, name string) (*models.City, error)
+	// FindAt returns known city nearest to the coordinates within CITY_SNAP_RADIUS, coordinates
+	// further from known cities are reverse geocoded and the city they are in is saved
+	FindAt(ctx context.Context, latitude, longitude float64) (*models.City, error)
+	// Search returns at most limit known cities whose name or alias starts with the query or is similar
+	// to it. Unknown query is geocoded, concurrent identical queries share one geocoder request and
+	// places found are kept in memory, one of them is saved only when Find selects it by name
+	Search(ctx context.Context, query string, limit int) ([]*models.City, error)
+}
+
+type Manager struct {
+	cfg             *config.Config
+	state           state.Stateful
+	mapsIntegration integrations.MapsIntegration
+	searches        *singleflight.Group
+}
+
+func New(cfg *config.Config, state state.Stateful, integration integrations.MapsIntegration) CityManager {
+	return &Manager{
+		cfg:             cfg,
+		state:           state,
+		mapsIntegration: integration,
+		searches:        &singleflight.Group{},
+	}
+}
+
+type guardKey struct{}
+
+// WithGuard returns context which calls guard before a city is geocoded, the city is not geocoded
+// and the guard error is returned when it fails
+func WithGuard(ctx context.Context, guard func() error) context.Context {
+	return context.WithValue(ctx, guardKey{}, guard)
+}
+
+func checkGuard(ctx context.Context) error {
+	guard, ok := ctx.Value(guardKey{}).(func() error)
+	if !ok {
+		return nil
+	}
+
+	return guard()
+}
+
+func (m *Manager) Find(ctx context.Context, name string) (*models.City, error) {
+	city, err := m.state.GetCity(name)
+	if !errors.Is(err, state.ErrNotFound) {
+		return city, err
+	}
+	candidate, err := m.state.GetCityCandidate(name)
+	if err == nil {
+		// the candidate is shared by searches, so its copy is saved
+		selected := *candidate
+		return m.save(&selected)
+	}
+	cities, err := m.state.GetCityCandidates(name)
+	cached := err == nil
+	if errors.Is(err, state.ErrNotFound) {
+		cities, err = m.geocodeName(ctx, name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(cities) == 0 {
+		return nil, ErrCityNotFound
+	}
+	if len(cities) > 1 {
+		views := make([]CityView, 0, len(cities))
+		for _, city := range cities {
+			views = append(views, NewView(city))
+		}
+		return nil, &AmbiguousError{Name: name, Candidates: views}
+	}
+
+	city = cities[0]
+	if cached {
+		// the place found by a search is shared by searches, so its copy is saved
+		selected := *city
+		city, err = m.save(&selected)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if !strings.EqualFold(city.Name, name) {
+		err = m.state.SaveCityAlias(&models.CityAlias{Alias: name, CityID: city.ID})
+		if err != nil {
+			zap.L().Error("error saving city alias", zap.Error(err))
+			return nil, err
+		}
+	}
+
+	return city, nil
+}
+
+// geocodeName saves places of the name, places of an ambiguous name are kept in memory for it
+func (m *Manager) geocodeName(ctx context.Context, name string) ([]*models.City, error) {
+	err := checkGuard(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates, err := m.mapsIntegration.GetCities(ctx, name)
+	if err != nil {
+		zap.L().Error("error getting city", zap.Error(err))
+		return nil, fmt.Errorf("%w: %w", ErrCityNotFound, err)
+	}
+	cities, err := m.saveAll(candidates)
+	if err != nil {
+		return nil, err
+	}
+	if len(cities) > 1 {
+		m.state.SaveCityCandidates(name, cities)
+	}
+
+	return cities, nil
+}
+
+func (m *Manager) FindAt(ctx context.Context, latitude, longitude float64) (*models.City, error) {
+	city, err := m.state.GetNearestCity(latitude, longitude, m.cfg.CitySnapRadius)
+	if !errors.Is(err, state.ErrNotFound) {
+		return city, err
+	}
+	err = checkGuard(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	city, err = m.mapsIntegration.GetCityAt(ctx, latitude, longitude)
+	if err != nil {
+		zap.L().Error("error reverse geocoding city", zap.Error(err))
+		return nil, fmt.Errorf("%w: %w", ErrCityNotFound, err)
+	}
+
+	// center of a large city may be further than the snap radius
+	return m.save(city)
+}
+
+func (m *Manager) Search(ctx context.Context, query string, limit int) ([]*models.City, error) {
+	key := slug.Make(query)
+	cities, err := m.state.SearchCities(key, limit)
+	if err != nil || len(cities) > 0 {
+		return cities, err
+	}
+
+	candidates, err := m.state.GetCityCandidates(key)
+	if errors.Is(err, state.ErrNotFound) {
+		// every caller spends its own limit, including those waiting for a request of another one
+		err = checkGuard(ctx)
+		if err != nil {
+			return nil, err
+		}
+		// the first caller runs the search for everyone waiting, its cancellation must not fail them
+		var found any
+		found, err, _ = m.searches.Do(key, func() (any, error) {
+			return m.geocode(context.WithoutCancel(ctx), query, key)
+		})
+		if err == nil {
+			candidates = found.([]*models.City)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return candidates[:min(len(candidates), limit)], nil
+}
+
+// geocode returns places of the query named the way saveAll would name them, unknown places are kept
+// in memory only. Places of an unknown query are cached as well, so typos are not geocoded again,
+// other geocoder failures are returned, so they are not mistaken for no places
+func (m *Manager) geocode(ctx context.Context, query, key string) ([]*models.City, error) {
+	found, err := m.mapsIntegration.GetCities(ctx, query)
+	// a prefix typed so far is often not a place
+	if errors.Is(err, integrations.ErrNotFound) {
+		m.state.SaveCityCandidates(key, []*models.City{})
+		return []*models.City{}, nil
+	}
+	if err != nil {
+		zap.L().Error("error geocoding city search", zap.String("query", query), zap.Error(err))
+		return nil, fmt.Errorf("%w: %w", ErrGeocoderUnavailable, err)
+	}
+
+	distinct := distinctPlaces(found)
+	candidates := make([]*models.City, 0, len(distinct))
+	for _, candidate := range distinct {
+		city, err := m.state.GetCityByPlaceID(candidate.GooglePlaceID)
+		if errors.Is(err, state.ErrNotFound) {
+			city = candidate
+			city.Name, err = m.uniqueName(candidate)
+		}
+		if err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, city)
+	}
+	m.state.SaveCityCandidates(key, candidates)
+
+	return candidates, nil
+}
+
+// saveAll saves candidates of distinct places
+func (m *Manager) saveAll(candidates []*models.City) ([]*models.City, error) {
+	distinct := distinctPlaces(candidates)
+	cities := make([]*models.City, 0, len(distinct))
+	for _, candidate := range distinct {
+		city, err := m.save(candidate)
+		if err != nil {
+			return nil, err
+		}
+		cities = append(cities, city)
+	}
+
+	return cities, nil
+}
+
+// distinctPlaces removes candidates of repeated places, names of several places are qualified with
+// their region and country, so none of them is found by the ambiguous name later
+func distinctPlaces(candidates []*models.City) []*models.City {
+	distinct := make([]*models.City, 0, len(candidates))
+	placeIDs := make(map[string]bool, len(candidates))
+	for _, candidate := range candidates {
+		if !placeIDs[candidate.GooglePlaceID] {
+			placeIDs[candidate.GooglePlaceID] = true
+			distinct = append(distinct, candidate)
+		}
+	}
+	if len(distinct) > 1 {
+		for _, candidate := range distinct {
+			candidate.Name = qualifiedName(candidate)
+		}
+	}
+
+	return distinct
+}
+
+// save returns known city of the candidate place, unknown place is saved under a free name
+func (m *Manager) save(candidate *models.City) (*models.City, error) {
+	city, err := m.state.GetCityByPlaceID(candidate.GooglePlaceID)
+	if !errors.Is(err, state.ErrNotFound) {
+		return city, err
+	}
+	candidate.Name, err = m.uniqueName(candidate)
+	if err != nil {
+		return nil, err
+	}
+
+	err = m.state.SaveCity(candidate)
+	if err != nil {
+		// the place may be saved by a concurrent request
+		city, findErr := m.state.GetCityByPlaceID(candidate.GooglePlaceID)
+		if findErr == nil {
+			return city, nil
+		}
+		zap.L().Error("error saving city", zap.Error(err))
+		return nil, err
+	}
+
+	return candidate, nil
+}
+
+// uniqueName returns the first free of the city name, its qualified name and the qualified name with
+// a numeric suffix, since another place of the same name may be known already
+func (m *Manager) uniqueName(city *models.City) (string, error) {
+	qualified := qualifiedName(city)
+	for i := 1; ; i++ {
+		candidate := city.Name
+		switch {
+		case i == 2:
+			candidate = qualified
+		case i > 2:
+			candidate = fmt.Sprintf("%s-%d", qualified, i-1)
+		}
+		_, err := m.state.GetCity(candidate)
+		if errors.Is(err, state.ErrNotFound) {
+			return candidate, nil
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+}
+
+// qualifiedName returns slug of the city formatted name, e.g. "paris-texas-united-states"
+func qualifiedName(city *models.City) string {
+	if city.FormattedName == "" {
+		return city.Name
+	}
+
+	return slug.Make(city.FormattedName)
+}
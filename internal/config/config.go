@@ -1,12 +1,38 @@
 package config
 
+import "time"
+
 type Config struct {
-	DNS              string   `mapstructure:"DNS" json:"DNS" yaml:"DNS"`
-	Database         database `mapstructure:"DATABASE" json:"DATABASE" yaml:"DATABASE"`
-	Port             string   `mapstructure:"PORT" yaml:"PORT" json:"PORT" default:"3000"`
-	FrontendURL      string   `mapstructure:"FRONTEND_URL" yaml:"FRONTEND_URL"`
-	GoogleMapsApiKey string   `mapstructure:"GOOGLE_MAPS_API_KEY" json:"GOOGLE_MAPS_API_KEY" yaml:"GOOGLE_MAPS_API_KEY"`
-	Mailer           mailer   `mapstructure:"MAILER" json:"MAILER" yaml:"MAILER"`
+	DNS               string   `mapstructure:"DNS" json:"DNS" yaml:"DNS"`
+	Database          database `mapstructure:"DATABASE" json:"DATABASE" yaml:"DATABASE"`
+	Port              string   `mapstructure:"PORT" yaml:"PORT" json:"PORT" default:"3000"`
+	FrontendURL       string   `mapstructure:"FRONTEND_URL" yaml:"FRONTEND_URL"`
+	TemplatesDir      string   `mapstructure:"TEMPLATES_DIR" json:"TEMPLATES_DIR" yaml:"TEMPLATES_DIR"`
+	ManageTokenSecret string   `mapstructure:"MANAGE_TOKEN_SECRET" json:"MANAGE_TOKEN_SECRET" yaml:"MANAGE_TOKEN_SECRET"`
+	// AdminToken is bearer token of admin API, the API is disabled when it is empty
+	AdminToken string `mapstructure:"ADMIN_TOKEN" json:"ADMIN_TOKEN" yaml:"ADMIN_TOKEN"`
+	// BounceWebhookToken is expected in token query parameter of bounce webhooks, they are disabled when it is empty
+	BounceWebhookToken string    `mapstructure:"BOUNCE_WEBHOOK_TOKEN" json:"BOUNCE_WEBHOOK_TOKEN" yaml:"BOUNCE_WEBHOOK_TOKEN"`
+	GoogleMapsApiKey   string    `mapstructure:"GOOGLE_MAPS_API_KEY" json:"GOOGLE_MAPS_API_KEY" yaml:"GOOGLE_MAPS_API_KEY"`
+	WeatherProvider    string    `mapstructure:"WEATHER_PROVIDER" json:"WEATHER_PROVIDER" yaml:"WEATHER_PROVIDER" default:"google"`
+	OpenMeteo          openMeteo `mapstructure:"OPEN_METEO" json:"OPEN_METEO" yaml:"OPEN_METEO"`
+	Failover           failover  `mapstructure:"FAILOVER" json:"FAILOVER" yaml:"FAILOVER"`
+	WeatherWorkers     int       `mapstructure:"WEATHER_WORKERS" json:"WEATHER_WORKERS" yaml:"WEATHER_WORKERS" default:"8"`
+	Mailer             mailer    `mapstructure:"MAILER" json:"MAILER" yaml:"MAILER"`
+	Outbox             outbox    `mapstructure:"OUTBOX" json:"OUTBOX" yaml:"OUTBOX"`
+	Cache              cache     `mapstructure:"CACHE" json:"CACHE" yaml:"CACHE"`
+	RateLimit          rateLimit `mapstructure:"RATE_LIMIT" json:"RATE_LIMIT" yaml:"RATE_LIMIT"`
+	Captcha            captcha   `mapstructure:"CAPTCHA" json:"CAPTCHA" yaml:"CAPTCHA"`
+	// ProxyHeader is header with client IP set by reverse proxy, e.g. X-Forwarded-For. It is read only
+	// from TrustedProxies, remote address is the client IP otherwise
+	ProxyHeader string `mapstructure:"PROXY_HEADER" json:"PROXY_HEADER" yaml:"PROXY_HEADER"`
+	// TrustedProxies are addresses or CIDR ranges of reverse proxies, e.g. "10.0.0.0/8,127.0.0.1"
+	TrustedProxies []string `mapstructure:"TRUSTED_PROXIES" json:"TRUSTED_PROXIES" yaml:"TRUSTED_PROXIES"`
+	// CitySnapRadius is distance in kilometers within which coordinates are snapped to a known city
+	CitySnapRadius float64 `mapstructure:"CITY_SNAP_RADIUS" json:"CITY_SNAP_RADIUS" yaml:"CITY_SNAP_RADIUS" default:"10"`
+	// JobLease is how long a job run claimed by a replica is kept while the replica renews it, a run of
+	// a replica which stopped is taken over by another one after the lease within the same slot
+	JobLease time.Duration `mapstructure:"JOB_LEASE" json:"JOB_LEASE" yaml:"JOB_LEASE" default:"2m"`
 }
 
 type database struct {
@@ -14,6 +40,18 @@ type database struct {
 	Host     string `mapstructure:"HOST" yaml:"HOST"`
 	User     string `mapstructure:"USER" yaml:"USER"`
 	Password string `mapstructure:"PASSWORD" yaml:"PASSWORD"`
+	// MigrateOnStart applies pending migrations when the server starts
+	MigrateOnStart bool `mapstructure:"MIGRATE_ON_START" yaml:"MIGRATE_ON_START" default:"true"`
+}
+
+type openMeteo struct {
+	ForecastURL  string `mapstructure:"FORECAST_URL" json:"FORECAST_URL" yaml:"FORECAST_URL" default:"https://api.open-meteo.com/v1/forecast"`
+	GeocodingURL string `mapstructure:"GEOCODING_URL" json:"GEOCODING_URL" yaml:"GEOCODING_URL" default:"https://geocoding-api.open-meteo.com/v1/search"`
+}
+
+type failover struct {
+	Threshold int           `mapstructure:"THRESHOLD" json:"THRESHOLD" yaml:"THRESHOLD" default:"3"`
+	Cooldown  time.Duration `mapstructure:"COOLDOWN" json:"COOLDOWN" yaml:"COOLDOWN" default:"1m"`
 }
 
 type mailer struct {
@@ -23,4 +61,66 @@ type mailer struct {
 	From     string `mapstructure:"FROM" yaml:"FROM"`
 	SMTP     string `mapstructure:"SMTP" yaml:"SMTP"`
 	Password string `mapstructure:"PASSWORD" yaml:"PASSWORD"`
+	// ReturnPath is envelope sender bounces are returned to, From is used when empty
+	ReturnPath string `mapstructure:"RETURN_PATH" json:"RETURN_PATH" yaml:"RETURN_PATH"`
+	ReplyTo    string `mapstructure:"REPLY_TO" json:"REPLY_TO" yaml:"REPLY_TO"`
+	DKIM       dkim   `mapstructure:"DKIM" json:"DKIM" yaml:"DKIM"`
+}
+
+// dkim signing is enabled when domain is set
+type dkim struct {
+	Domain         string `mapstructure:"DOMAIN" json:"DOMAIN" yaml:"DOMAIN"`
+	Selector       string `mapstructure:"SELECTOR" json:"SELECTOR" yaml:"SELECTOR"`
+	PrivateKeyPath string `mapstructure:"PRIVATE_KEY_PATH" json:"PRIVATE_KEY_PATH" yaml:"PRIVATE_KEY_PATH"`
+}
+
+type outbox struct {
+	PollInterval time.Duration `mapstructure:"POLL_INTERVAL" json:"POLL_INTERVAL" yaml:"POLL_INTERVAL" default:"5s"`
+	BatchSize    int           `mapstructure:"BATCH_SIZE" json:"BATCH_SIZE" yaml:"BATCH_SIZE" default:"50"`
+	Workers      int           `mapstructure:"WORKERS" json:"WORKERS" yaml:"WORKERS" default:"8"`
+	MaxAttempts  int           `mapstructure:"MAX_ATTEMPTS" json:"MAX_ATTEMPTS" yaml:"MAX_ATTEMPTS" default:"8"`
+	BaseBackoff  time.Duration `mapstructure:"BASE_BACKOFF" json:"BASE_BACKOFF" yaml:"BASE_BACKOFF" default:"30s"`
+	MaxBackoff   time.Duration `mapstructure:"MAX_BACKOFF" json:"MAX_BACKOFF" yaml:"MAX_BACKOFF" default:"1h"`
+	// Lease is how long claimed messages are skipped by other dispatchers, a message of a dispatcher which
+	// stopped before delivering it is retried after the lease
+	Lease time.Duration `mapstructure:"LEASE" json:"LEASE" yaml:"LEASE" default:"5m"`
+}
+
+type cache struct {
+	Size            int           `mapstructure:"SIZE" json:"SIZE" yaml:"SIZE" default:"10000"`
+	UserTTL         time.Duration `mapstructure:"USER_TTL" json:"USER_TTL" yaml:"USER_TTL" default:"10m"`
+	CityTTL         time.Duration `mapstructure:"CITY_TTL" json:"CITY_TTL" yaml:"CITY_TTL" default:"24h"`
+	WeatherTTL      time.Duration `mapstructure:"WEATHER_TTL" json:"WEATHER_TTL" yaml:"WEATHER_TTL" default:"10m"`
+	ForecastTTL     time.Duration `mapstructure:"FORECAST_TTL" json:"FORECAST_TTL" yaml:"FORECAST_TTL" default:"1h"`
+	TokenTTL        time.Duration `mapstructure:"TOKEN_TTL" json:"TOKEN_TTL" yaml:"TOKEN_TTL" default:"10m"`
+	SubscriptionTTL time.Duration `mapstructure:"SUBSCRIPTION_TTL" json:"SUBSCRIPTION_TTL" yaml:"SUBSCRIPTION_TTL" default:"10m"`
+	// SearchTTL keeps city search results, cities saved meanwhile are not found by a cached query
+	SearchTTL time.Duration `mapstructure:"SEARCH_TTL" json:"SEARCH_TTL" yaml:"SEARCH_TTL" default:"10m"`
+}
+
+// rateLimit limits POST /subscribe, every limit allows the number of requests per period with bursts
+// of the same size and is disabled when requests is 0
+type rateLimit struct {
+	// Store keeps token buckets, "memory" limits every replica separately, "postgres" shares them
+	Store         string        `mapstructure:"STORE" json:"STORE" yaml:"STORE" default:"memory"`
+	IPRequests    int           `mapstructure:"IP_REQUESTS" json:"IP_REQUESTS" yaml:"IP_REQUESTS" default:"20"`
+	IPPeriod      time.Duration `mapstructure:"IP_PERIOD" json:"IP_PERIOD" yaml:"IP_PERIOD" default:"1h"`
+	EmailRequests int           `mapstructure:"EMAIL_REQUESTS" json:"EMAIL_REQUESTS" yaml:"EMAIL_REQUESTS" default:"3"`
+	EmailPeriod   time.Duration `mapstructure:"EMAIL_PERIOD" json:"EMAIL_PERIOD" yaml:"EMAIL_PERIOD" default:"1h"`
+	// NewCity limits cities of a client IP which are not known yet and have to be geocoded
+	NewCityRequests int           `mapstructure:"NEW_CITY_REQUESTS" json:"NEW_CITY_REQUESTS" yaml:"NEW_CITY_REQUESTS" default:"10"`
+	NewCityPeriod   time.Duration `mapstructure:"NEW_CITY_PERIOD" json:"NEW_CITY_PERIOD" yaml:"NEW_CITY_PERIOD" default:"1h"`
+	// Search limits city search queries of a client IP which are not known yet and have to be geocoded
+	SearchRequests int           `mapstructure:"SEARCH_REQUESTS" json:"SEARCH_REQUESTS" yaml:"SEARCH_REQUESTS" default:"30"`
+	SearchPeriod   time.Duration `mapstructure:"SEARCH_PERIOD" json:"SEARCH_PERIOD" yaml:"SEARCH_PERIOD" default:"1h"`
+}
+
+// captcha is verified on POST /subscribe when provider is set
+type captcha struct {
+	// Provider is one of "hcaptcha", "turnstile", "recaptcha" or "stub" which accepts StubToken only
+	Provider string `mapstructure:"PROVIDER" json:"PROVIDER" yaml:"PROVIDER"`
+	Secret   string `mapstructure:"SECRET" json:"SECRET" yaml:"SECRET"`
+	// VerifyURL overrides siteverify endpoint of the provider
+	VerifyURL string `mapstructure:"VERIFY_URL" json:"VERIFY_URL" yaml:"VERIFY_URL"`
+	StubToken string `mapstructure:"STUB_TOKEN" json:"STUB_TOKEN" yaml:"STUB_TOKEN"`
 }
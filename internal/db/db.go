@@ -4,29 +4,32 @@ import (
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"weather-subscriptions/internal/config"
-	"weather-subscriptions/internal/db/models"
+	"weather-subscriptions/internal/db/migrations"
 )
 
+// Connect opens database connection and applies pending migrations if it is enabled by config
 func Connect(config *config.Config) (*gorm.DB, error) {
-	database, err := gorm.Open(postgres.Open(config.DNS), &gorm.Config{})
+	database, err := Open(config)
 	if err != nil {
 		return nil, err
 	}
+	if !config.Database.MigrateOnStart {
+		return database, nil
+	}
 
-	err = database.Exec("CREATE EXTENSION IF NOT EXISTS \"uuid-ossp\";").Error
+	migrator, err := migrations.New(database)
 	if err != nil {
 		return nil, err
 	}
-	err = database.AutoMigrate(
-		&models.City{},
-		&models.User{},
-		&models.Token{},
-		&models.Weather{},
-		&models.Subscription{},
-	)
+	err = migrator.Up()
 	if err != nil {
 		return nil, err
 	}
 
 	return database, nil
 }
+
+// Open opens database connection without touching the schema
+func Open(config *config.Config) (*gorm.DB, error) {
+	return gorm.Open(postgres.Open(config.DNS), &gorm.Config{})
+}
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS subscriptions;
+DROP TABLE IF EXISTS weathers;
+DROP TABLE IF EXISTS tokens;
+DROP TABLE IF EXISTS users;
+DROP TABLE IF EXISTS cities;
@@ -0,0 +1,42 @@
+-- Schema created by the former AutoMigrate, existing databases keep their tables
+CREATE EXTENSION IF NOT EXISTS "uuid-ossp";
+
+CREATE TABLE IF NOT EXISTS cities (
+    id              text PRIMARY KEY DEFAULT uuid_generate_v4(),
+    name            text    NOT NULL UNIQUE,
+    longitude       decimal NOT NULL,
+    latitude        decimal NOT NULL,
+    google_place_id text    NOT NULL UNIQUE
+);
+
+CREATE TABLE IF NOT EXISTS users (
+    id      text PRIMARY KEY DEFAULT uuid_generate_v4(),
+    email   text NOT NULL UNIQUE,
+    city_id text NOT NULL REFERENCES cities (id)
+);
+
+CREATE TABLE IF NOT EXISTS tokens (
+    token             text PRIMARY KEY DEFAULT uuid_generate_v4(),
+    type              text        NOT NULL,
+    subscription_type text,
+    expiry_at         timestamptz NOT NULL CHECK (expiry_at > now()),
+    user_id           text        NOT NULL REFERENCES users (id) ON UPDATE CASCADE ON DELETE CASCADE,
+    deleted_at        timestamptz
+);
+CREATE UNIQUE INDEX IF NOT EXISTS uni_user_id_token_type ON tokens (type, user_id);
+
+CREATE TABLE IF NOT EXISTS weathers (
+    id          text PRIMARY KEY DEFAULT uuid_generate_v4(),
+    time        timestamptz NOT NULL,
+    temperature decimal     NOT NULL,
+    humidity    bigint      NOT NULL,
+    description text        NOT NULL,
+    city_id     text        NOT NULL REFERENCES cities (id) ON UPDATE CASCADE ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS subscriptions (
+    id        text PRIMARY KEY DEFAULT uuid_generate_v4(),
+    frequency text NOT NULL,
+    user_id   text NOT NULL REFERENCES users (id) ON UPDATE CASCADE ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_subscriptions_frequency ON subscriptions (frequency);
@@ -0,0 +1,30 @@
+-- Users keep the city of their first subscription, the rest of subscriptions are dropped
+ALTER TABLE users ADD COLUMN city_id text REFERENCES cities (id);
+UPDATE users SET city_id = (
+    SELECT subscriptions.city_id FROM subscriptions
+    WHERE subscriptions.user_id = users.id
+    ORDER BY subscriptions.id
+    LIMIT 1
+);
+DELETE FROM users WHERE city_id IS NULL;
+ALTER TABLE users ALTER COLUMN city_id SET NOT NULL;
+DELETE FROM subscriptions WHERE city_id <> (SELECT users.city_id FROM users WHERE users.id = subscriptions.user_id);
+
+DROP INDEX uni_subscription_id_token_type;
+ALTER TABLE tokens
+    ADD COLUMN user_id text REFERENCES users (id) ON UPDATE CASCADE ON DELETE CASCADE,
+    ADD COLUMN subscription_type text;
+UPDATE tokens SET user_id = subscriptions.user_id, subscription_type = subscriptions.frequency
+FROM subscriptions WHERE tokens.subscription_id = subscriptions.id;
+DELETE FROM tokens WHERE user_id IS NULL;
+ALTER TABLE tokens
+    ALTER COLUMN user_id SET NOT NULL,
+    DROP COLUMN subscription_id;
+CREATE UNIQUE INDEX uni_user_id_token_type ON tokens (type, user_id);
+
+DELETE FROM subscriptions WHERE NOT confirmed;
+DROP INDEX uni_subscription_user_id_city_id;
+DROP INDEX idx_subscriptions_confirmed;
+ALTER TABLE subscriptions
+    DROP COLUMN confirmed,
+    DROP COLUMN city_id;
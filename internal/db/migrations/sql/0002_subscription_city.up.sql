@@ -0,0 +1,21 @@
+-- Subscriptions own the city, tokens are bound to a subscription instead of a user
+ALTER TABLE subscriptions
+    ADD COLUMN city_id   text REFERENCES cities (id) ON UPDATE CASCADE ON DELETE CASCADE,
+    ADD COLUMN confirmed boolean NOT NULL DEFAULT true;
+UPDATE subscriptions SET city_id = users.city_id FROM users WHERE subscriptions.user_id = users.id;
+ALTER TABLE subscriptions
+    ALTER COLUMN city_id SET NOT NULL,
+    ALTER COLUMN confirmed SET DEFAULT false;
+CREATE INDEX idx_subscriptions_confirmed ON subscriptions (confirmed);
+CREATE UNIQUE INDEX uni_subscription_user_id_city_id ON subscriptions (user_id, city_id);
+
+ALTER TABLE tokens ADD COLUMN subscription_id text REFERENCES subscriptions (id) ON UPDATE CASCADE ON DELETE CASCADE;
+UPDATE tokens SET subscription_id = subscriptions.id FROM subscriptions WHERE tokens.user_id = subscriptions.user_id;
+DELETE FROM tokens WHERE subscription_id IS NULL;
+ALTER TABLE tokens
+    ALTER COLUMN subscription_id SET NOT NULL,
+    DROP COLUMN user_id,
+    DROP COLUMN subscription_type;
+CREATE UNIQUE INDEX uni_subscription_id_token_type ON tokens (type, subscription_id) WHERE deleted_at IS NULL;
+
+ALTER TABLE users DROP COLUMN city_id;
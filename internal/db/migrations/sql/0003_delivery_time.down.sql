@@ -0,0 +1,6 @@
+ALTER TABLE cities DROP COLUMN time_zone;
+
+DROP INDEX idx_subscriptions_timezone;
+ALTER TABLE subscriptions
+    DROP COLUMN delivery_hour,
+    DROP COLUMN timezone;
@@ -0,0 +1,6 @@
+ALTER TABLE subscriptions
+    ADD COLUMN timezone      text   NOT NULL DEFAULT 'UTC',
+    ADD COLUMN delivery_hour bigint NOT NULL DEFAULT 12;
+CREATE INDEX idx_subscriptions_timezone ON subscriptions (timezone);
+
+ALTER TABLE cities ADD COLUMN time_zone text;
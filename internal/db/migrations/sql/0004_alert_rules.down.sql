@@ -0,0 +1,2 @@
+DELETE FROM subscriptions WHERE frequency = 'alert';
+DROP TABLE alert_rules;
@@ -0,0 +1,10 @@
+CREATE TABLE alert_rules (
+    id              text PRIMARY KEY DEFAULT uuid_generate_v4(),
+    subscription_id text    NOT NULL REFERENCES subscriptions (id) ON UPDATE CASCADE ON DELETE CASCADE,
+    metric          text    NOT NULL,
+    operator        text    NOT NULL,
+    value           text    NOT NULL,
+    triggered       boolean NOT NULL DEFAULT false,
+    triggered_at    timestamptz
+);
+CREATE INDEX idx_alert_rules_subscription_id ON alert_rules (subscription_id);
@@ -0,0 +1 @@
+ALTER TABLE weathers DROP COLUMN provider;
@@ -0,0 +1 @@
+ALTER TABLE weathers ADD COLUMN provider text;
@@ -0,0 +1,13 @@
+CREATE TABLE outbox (
+    id              text PRIMARY KEY DEFAULT uuid_generate_v4(),
+    "to"            text        NOT NULL,
+    subject         text        NOT NULL,
+    body            text        NOT NULL,
+    status          text        NOT NULL DEFAULT 'pending',
+    attempts        bigint      NOT NULL DEFAULT 0,
+    next_attempt_at timestamptz NOT NULL,
+    last_error      text,
+    created_at      timestamptz,
+    sent_at         timestamptz
+);
+CREATE INDEX idx_outbox_status_next_attempt_at ON outbox (status, next_attempt_at);
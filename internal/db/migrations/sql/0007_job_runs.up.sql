@@ -0,0 +1,13 @@
+-- A row per scheduled job slot, the replica which inserted it is the one running the job
+CREATE TABLE job_runs (
+    job         text        NOT NULL,
+    slot        timestamptz NOT NULL,
+    runner      text        NOT NULL,
+    started_at  timestamptz NOT NULL,
+    finished_at timestamptz,
+    sent        bigint      NOT NULL DEFAULT 0,
+    failed      bigint      NOT NULL DEFAULT 0,
+    skipped     bigint      NOT NULL DEFAULT 0,
+    error       text,
+    PRIMARY KEY (job, slot)
+);
@@ -0,0 +1,2 @@
+DELETE FROM tokens WHERE type = 'manage';
+ALTER TABLE subscriptions DROP COLUMN paused;
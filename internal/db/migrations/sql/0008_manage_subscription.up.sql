@@ -0,0 +1,2 @@
+-- Paused subscriptions keep their preferences but are not delivered
+ALTER TABLE subscriptions ADD COLUMN paused boolean NOT NULL DEFAULT false;
@@ -0,0 +1,2 @@
+-- Snoozed subscriptions are skipped until the time has passed
+ALTER TABLE subscriptions ADD COLUMN snoozed_until timestamptz;
@@ -0,0 +1,3 @@
+DROP TABLE forecast_hours;
+DROP TABLE forecast_days;
+DROP TABLE forecasts;
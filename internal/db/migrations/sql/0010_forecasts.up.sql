@@ -0,0 +1,30 @@
+-- Forecasts replace each other, only the latest forecast of a city is kept
+CREATE TABLE forecasts (
+    id       text PRIMARY KEY DEFAULT uuid_generate_v4(),
+    time     timestamptz NOT NULL,
+    provider text,
+    city_id  text        NOT NULL REFERENCES cities (id) ON UPDATE CASCADE ON DELETE CASCADE
+);
+CREATE INDEX idx_forecasts_city_id ON forecasts (city_id);
+
+CREATE TABLE forecast_days (
+    id                        text PRIMARY KEY DEFAULT uuid_generate_v4(),
+    forecast_id               text    NOT NULL REFERENCES forecasts (id) ON UPDATE CASCADE ON DELETE CASCADE,
+    date                      date    NOT NULL,
+    max_temperature           decimal NOT NULL,
+    min_temperature           decimal NOT NULL,
+    precipitation_probability bigint  NOT NULL,
+    description               text    NOT NULL
+);
+CREATE INDEX idx_forecast_days_forecast_id ON forecast_days (forecast_id);
+
+CREATE TABLE forecast_hours (
+    id                        text PRIMARY KEY DEFAULT uuid_generate_v4(),
+    forecast_id               text        NOT NULL REFERENCES forecasts (id) ON UPDATE CASCADE ON DELETE CASCADE,
+    time                      timestamptz NOT NULL,
+    temperature               decimal     NOT NULL,
+    humidity                  bigint      NOT NULL,
+    precipitation_probability bigint      NOT NULL,
+    description               text        NOT NULL
+);
+CREATE INDEX idx_forecast_hours_forecast_id ON forecast_hours (forecast_id);
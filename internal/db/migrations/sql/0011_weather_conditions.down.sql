@@ -0,0 +1,18 @@
+ALTER TABLE weathers
+    DROP COLUMN wind_speed,
+    DROP COLUMN wind_gust,
+    DROP COLUMN wind_direction,
+    DROP COLUMN wind_cardinal,
+    DROP COLUMN precipitation_probability,
+    DROP COLUMN precipitation_type,
+    DROP COLUMN precipitation,
+    DROP COLUMN thunderstorm_probability,
+    DROP COLUMN uv_index,
+    DROP COLUMN pressure,
+    DROP COLUMN visibility,
+    DROP COLUMN cloud_cover,
+    DROP COLUMN history_temperature_change,
+    DROP COLUMN history_max_temperature,
+    DROP COLUMN history_min_temperature,
+    DROP COLUMN history_precipitation,
+    DROP COLUMN history_available;
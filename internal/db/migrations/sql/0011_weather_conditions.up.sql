@@ -0,0 +1,19 @@
+-- Full current conditions, history columns summarize the last 24 hours when provider reports them
+ALTER TABLE weathers
+    ADD COLUMN wind_speed                 decimal NOT NULL DEFAULT 0,
+    ADD COLUMN wind_gust                  decimal NOT NULL DEFAULT 0,
+    ADD COLUMN wind_direction             bigint  NOT NULL DEFAULT 0,
+    ADD COLUMN wind_cardinal              text,
+    ADD COLUMN precipitation_probability  bigint  NOT NULL DEFAULT 0,
+    ADD COLUMN precipitation_type         text,
+    ADD COLUMN precipitation              decimal NOT NULL DEFAULT 0,
+    ADD COLUMN thunderstorm_probability   bigint  NOT NULL DEFAULT 0,
+    ADD COLUMN uv_index                   bigint  NOT NULL DEFAULT 0,
+    ADD COLUMN pressure                   decimal NOT NULL DEFAULT 0,
+    ADD COLUMN visibility                 decimal NOT NULL DEFAULT 0,
+    ADD COLUMN cloud_cover                bigint  NOT NULL DEFAULT 0,
+    ADD COLUMN history_temperature_change decimal NOT NULL DEFAULT 0,
+    ADD COLUMN history_max_temperature    decimal NOT NULL DEFAULT 0,
+    ADD COLUMN history_min_temperature    decimal NOT NULL DEFAULT 0,
+    ADD COLUMN history_precipitation      decimal NOT NULL DEFAULT 0,
+    ADD COLUMN history_available          boolean NOT NULL DEFAULT false;
@@ -0,0 +1,4 @@
+DROP INDEX idx_weathers_city_id_language;
+ALTER TABLE forecasts DROP COLUMN units, DROP COLUMN language;
+ALTER TABLE weathers DROP COLUMN units, DROP COLUMN language;
+ALTER TABLE users DROP COLUMN units, DROP COLUMN language;
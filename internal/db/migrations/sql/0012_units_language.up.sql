@@ -0,0 +1,13 @@
+-- Subscriber preferences, weather and forecasts record units and language they were fetched in
+ALTER TABLE users
+    ADD COLUMN units    text NOT NULL DEFAULT 'metric',
+    ADD COLUMN language text NOT NULL DEFAULT 'en';
+
+ALTER TABLE weathers
+    ADD COLUMN units    text NOT NULL DEFAULT 'metric',
+    ADD COLUMN language text NOT NULL DEFAULT 'en';
+CREATE INDEX idx_weathers_city_id_language ON weathers (city_id, language);
+
+ALTER TABLE forecasts
+    ADD COLUMN units    text NOT NULL DEFAULT 'metric',
+    ADD COLUMN language text NOT NULL DEFAULT 'en';
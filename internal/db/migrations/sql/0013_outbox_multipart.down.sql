@@ -0,0 +1 @@
+ALTER TABLE outbox DROP COLUMN text, DROP COLUMN headers;
@@ -0,0 +1,4 @@
+-- Outbox messages carry plain-text alternative and custom headers such as List-Unsubscribe
+ALTER TABLE outbox
+    ADD COLUMN text    text NOT NULL DEFAULT '',
+    ADD COLUMN headers text;
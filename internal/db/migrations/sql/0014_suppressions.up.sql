@@ -0,0 +1,9 @@
+-- Addresses which hard bounced or complained, subscriptions of their users are not delivered
+CREATE TABLE suppressions (
+    email      text PRIMARY KEY,
+    reason     text        NOT NULL,
+    source     text        NOT NULL,
+    detail     text,
+    created_at timestamptz NOT NULL DEFAULT now(),
+    updated_at timestamptz NOT NULL DEFAULT now()
+);
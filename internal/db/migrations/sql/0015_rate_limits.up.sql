@@ -0,0 +1,9 @@
+-- Token buckets of rate limits shared by all replicas when RATE_LIMIT_STORE is postgres
+CREATE TABLE rate_limits (
+    key         text PRIMARY KEY,
+    tokens      double precision NOT NULL,
+    refilled_at timestamptz      NOT NULL,
+    full_at     timestamptz      NOT NULL
+);
+
+CREATE INDEX idx_rate_limits_full_at ON rate_limits (full_at);
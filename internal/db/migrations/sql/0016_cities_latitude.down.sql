@@ -0,0 +1 @@
+DROP INDEX idx_cities_latitude;
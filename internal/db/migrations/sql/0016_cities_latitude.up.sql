@@ -0,0 +1,2 @@
+-- Nearest city lookup of coordinates prefilters cities by latitude
+CREATE INDEX idx_cities_latitude ON cities (latitude);
@@ -0,0 +1,3 @@
+DROP TABLE city_aliases;
+
+ALTER TABLE cities DROP COLUMN formatted_name, DROP COLUMN region, DROP COLUMN country;
@@ -0,0 +1,14 @@
+-- Places given by the geocoder, existing cities keep empty values until they are geocoded again
+ALTER TABLE cities
+    ADD COLUMN formatted_name text NOT NULL DEFAULT '',
+    ADD COLUMN region         text NOT NULL DEFAULT '',
+    ADD COLUMN country        text NOT NULL DEFAULT '';
+
+-- Spellings users typed for a city which differ from its canonical name
+CREATE TABLE city_aliases (
+    alias      text PRIMARY KEY,
+    city_id    text        NOT NULL REFERENCES cities (id) ON UPDATE CASCADE ON DELETE CASCADE,
+    created_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_city_aliases_city_id ON city_aliases (city_id);
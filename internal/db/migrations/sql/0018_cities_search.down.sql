@@ -0,0 +1,2 @@
+DROP INDEX idx_city_aliases_alias_trgm;
+DROP INDEX idx_cities_name_trgm;
@@ -0,0 +1,5 @@
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+-- Trigram indexes serve both prefix LIKE and similarity search of city names and aliases
+CREATE INDEX idx_cities_name_trgm ON cities USING gin (name gin_trgm_ops);
+CREATE INDEX idx_city_aliases_alias_trgm ON city_aliases USING gin (alias gin_trgm_ops);
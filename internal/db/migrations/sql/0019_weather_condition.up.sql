@@ -0,0 +1,2 @@
+-- Language independent condition, alert rules of the description metric are matched against it
+ALTER TABLE weathers ADD COLUMN condition text NOT NULL DEFAULT '';
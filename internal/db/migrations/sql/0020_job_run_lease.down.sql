@@ -0,0 +1 @@
+ALTER TABLE job_runs DROP COLUMN leased_until;
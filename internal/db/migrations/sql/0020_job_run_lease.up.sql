@@ -0,0 +1,2 @@
+-- Runs are leased while in progress, so a run of a replica which stopped can be taken over in its slot
+ALTER TABLE job_runs ADD COLUMN leased_until timestamptz NOT NULL DEFAULT now();
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_outbox_dedup_key;
+ALTER TABLE outbox DROP COLUMN dedup_key;
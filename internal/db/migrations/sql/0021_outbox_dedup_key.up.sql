@@ -0,0 +1,3 @@
+-- Emails queued by scheduled jobs are keyed by the job slot, so a slot taken over does not queue them twice
+ALTER TABLE outbox ADD COLUMN dedup_key text;
+CREATE UNIQUE INDEX idx_outbox_dedup_key ON outbox (dedup_key);
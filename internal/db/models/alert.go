@@ -0,0 +1,82 @@
+package models
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type AlertRule struct {
+	ID             string `gorm:"primaryKey;default:uuid_generate_v4()"`
+	SubscriptionID string `gorm:"text;not null;index"`
+	Metric         string `gorm:"text;not null"`
+	Operator       string `gorm:"text;not null"`
+	Value          string `gorm:"text;not null"`
+	Triggered      bool   `gorm:"not null;default:false"`
+	TriggeredAt    *time.Time
+}
+
+type AlertMetric string
+
+const (
+	TemperatureMetric AlertMetric = "temperature"
+	HumidityMetric    AlertMetric = "humidity"
+	DescriptionMetric AlertMetric = "description"
+)
+
+type AlertOperator string
+
+const (
+	LessThan    AlertOperator = "lt"
+	GreaterThan AlertOperator = "gt"
+	Contains    AlertOperator = "contains"
+)
+
+// Matches reports whether the weather satisfies the rule condition
+func (r *AlertRule) Matches(weather *Weather) bool {
+	switch AlertMetric(r.Metric) {
+	case TemperatureMetric:
+		return r.compare(weather.Temperature)
+	case HumidityMetric:
+		return r.compare(float64(weather.Humidity))
+	case DescriptionMetric:
+		// description is in subscriber language, weather saved before conditions were recorded has only it
+		condition := weather.Condition
+		if condition == "" {
+			condition = weather.Description
+		}
+		return AlertOperator(r.Operator) == Contains &&
+			strings.Contains(strings.ToLower(condition), strings.ToLower(r.Value))
+	default:
+		return false
+	}
+}
+
+// String returns human-readable rule condition
+func (r *AlertRule) String() string {
+	switch AlertOperator(r.Operator) {
+	case LessThan:
+		return fmt.Sprintf("%s below %s", r.Metric, r.Value)
+	case GreaterThan:
+		return fmt.Sprintf("%s above %s", r.Metric, r.Value)
+	default:
+		return fmt.Sprintf("%s contains \"%s\"", r.Metric, r.Value)
+	}
+}
+
+func (r *AlertRule) compare(actual float64) bool {
+	threshold, err := strconv.ParseFloat(r.Value, 64)
+	if err != nil {
+		return false
+	}
+
+	switch AlertOperator(r.Operator) {
+	case LessThan:
+		return actual < threshold
+	case GreaterThan:
+		return actual > threshold
+	default:
+		return false
+	}
+}
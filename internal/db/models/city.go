@@ -1,14 +1,31 @@
 package models
 
-import "strconv"
+import (
+	"strconv"
+	"time"
+)
 
+// City is a place of the geocoding provider identified by GooglePlaceID. Name is its unique canonical
+// slug, other spellings users typed are kept as CityAlias
 type City struct {
 	ID            string  `gorm:"primaryKey;default:uuid_generate_v4()"`
 	Name          string  `gorm:"not null;unique"`
 	Longitude     float64 `gorm:"not null"`
 	Latitude      float64 `gorm:"not null"`
 	GooglePlaceID string  `gorm:"not null;unique"`
-	Users         []User  `gorm:"foreignKey:CityID"`
+	// FormattedName, Region and Country are empty for cities geocoded before they were stored
+	FormattedName string         `gorm:"text;not null;default:''"`
+	Region        string         `gorm:"text;not null;default:''"`
+	Country       string         `gorm:"text;not null;default:''"`
+	TimeZone      string         `gorm:"text"`
+	Subscriptions []Subscription `gorm:"foreignKey:CityID"`
+}
+
+// CityAlias is a lowercase spelling of the city users typed, which differs from its name
+type CityAlias struct {
+	Alias     string `gorm:"primaryKey;text"`
+	CityID    string `gorm:"text;not null"`
+	CreatedAt time.Time
 }
 
 type Coordinates struct {
@@ -16,6 +33,16 @@ type Coordinates struct {
 	Lat  string
 }
 
+// DisplayName returns formatted name of the city, e.g. "Paris, Texas, United States", or its name
+// when formatted one is not known
+func (c *City) DisplayName() string {
+	if c.FormattedName != "" {
+		return c.FormattedName
+	}
+
+	return c.Name
+}
+
 func (c *City) GetStringCoordinates() Coordinates {
 	longitudeStr := strconv.FormatFloat(c.Longitude, 'f', -1, 64)
 	latitudeStr := strconv.FormatFloat(c.Latitude, 'f', -1, 64)
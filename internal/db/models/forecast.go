@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// Forecast is a daily and hourly weather forecast of a city fetched in one go
+type Forecast struct {
+	ID       string         `gorm:"primaryKey;default:uuid_generate_v4()"`
+	Time     time.Time      `gorm:"not null"`
+	Units    string         `gorm:"text;not null;default:'metric'"`
+	Language string         `gorm:"text;not null;default:'en'"`
+	Provider string         `gorm:"text"`
+	CityID   string         `gorm:"not null;index"`
+	City     City           `gorm:"foreignKey:CityID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	Days     []ForecastDay  `gorm:"foreignKey:ForecastID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	Hours    []ForecastHour `gorm:"foreignKey:ForecastID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+}
+
+// ForecastDay is a forecast for a calendar day of the city
+type ForecastDay struct {
+	ID                       string    `gorm:"primaryKey;default:uuid_generate_v4()"`
+	ForecastID               string    `gorm:"not null;index"`
+	Date                     time.Time `gorm:"type:date;not null"`
+	MaxTemperature           float64   `gorm:"not null"`
+	MinTemperature           float64   `gorm:"not null"`
+	PrecipitationProbability int       `gorm:"not null"`
+	Description              string    `gorm:"not null"`
+}
+
+// ForecastHour is a forecast for an hour starting at Time
+type ForecastHour struct {
+	ID                       string    `gorm:"primaryKey;default:uuid_generate_v4()"`
+	ForecastID               string    `gorm:"not null;index"`
+	Time                     time.Time `gorm:"not null"`
+	Temperature              float64   `gorm:"not null"`
+	Humidity                 int       `gorm:"not null"`
+	PrecipitationProbability int       `gorm:"not null"`
+	Description              string    `gorm:"not null"`
+}
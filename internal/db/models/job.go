@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+type JobRun struct {
+	Job        string    `gorm:"primaryKey;text"`
+	Slot       time.Time `gorm:"primaryKey"`
+	Runner     string    `gorm:"text;not null"`
+	StartedAt  time.Time `gorm:"not null"`
+	FinishedAt *time.Time
+	Sent       int    `gorm:"not null;default:0"`
+	Failed     int    `gorm:"not null;default:0"`
+	Skipped    int    `gorm:"not null;default:0"`
+	Error      string `gorm:"text"`
+
+	// LeasedUntil is renewed while the run is in progress, unfinished run is taken over after it
+	LeasedUntil time.Time `gorm:"not null"`
+}
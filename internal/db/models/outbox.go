@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+type OutboxMessage struct {
+	ID            string            `gorm:"primaryKey;default:uuid_generate_v4()"`
+	To            []string          `gorm:"serializer:json;not null"`
+	Subject       string            `gorm:"not null"`
+	Body          string            `gorm:"not null"`
+	Text          string            `gorm:"text;not null;default:''"`
+	Headers       map[string]string `gorm:"serializer:json"`
+	Status        string            `gorm:"text;not null;default:'pending';index:idx_outbox_status_next_attempt_at"`
+	Attempts      int               `gorm:"not null;default:0"`
+	NextAttemptAt time.Time         `gorm:"not null;index:idx_outbox_status_next_attempt_at"`
+	LastError     string            `gorm:"text"`
+	CreatedAt     time.Time
+	SentAt        *time.Time
+
+	// DedupKey identifies email of a job slot, a slot run again does not queue the email twice
+	DedupKey *string `gorm:"text;uniqueIndex"`
+}
+
+func (OutboxMessage) TableName() string {
+	return "outbox"
+}
+
+type OutboxStatus string
+
+const (
+	OutboxPending OutboxStatus = "pending"
+	OutboxSent    OutboxStatus = "sent"
+	OutboxDead    OutboxStatus = "dead"
+)
@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// RateLimit is a token bucket of a rate limit key. The bucket is refilled at RefilledAt and is
+// full again at FullAt, buckets past FullAt can be removed since a missing bucket is a full one
+type RateLimit struct {
+	Key        string    `gorm:"primaryKey;text"`
+	Tokens     float64   `gorm:"not null"`
+	RefilledAt time.Time `gorm:"not null"`
+	FullAt     time.Time `gorm:"not null"`
+}
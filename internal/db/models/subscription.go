@@ -1,10 +1,21 @@
 package models
 
+import "time"
+
 type Subscription struct {
-	ID        string `gorm:"primaryKey;default:uuid_generate_v4()"`
-	Frequency string `gorm:"text;not null;index"`
-	UserID    string `gorm:"text;not null"`
-	User      User   `gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+	ID           string `gorm:"primaryKey;default:uuid_generate_v4()"`
+	Frequency    string `gorm:"text;not null;index"`
+	Confirmed    bool   `gorm:"not null;default:false;index"`
+	Timezone     string `gorm:"text;not null;default:'UTC';index"`
+	DeliveryHour int    `gorm:"not null;default:12"`
+	Paused       bool   `gorm:"not null;default:false"`
+	// SnoozedUntil postpones deliveries, subscription is resumed once it has passed
+	SnoozedUntil *time.Time
+	UserID       string      `gorm:"text;not null;uniqueIndex:uni_subscription_user_id_city_id"`
+	User         User        `gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+	CityID       string      `gorm:"text;not null;uniqueIndex:uni_subscription_user_id_city_id"`
+	City         City        `gorm:"foreignKey:CityID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+	AlertRules   []AlertRule `gorm:"foreignKey:SubscriptionID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
 }
 
 type SubscriptionType string
@@ -12,11 +23,38 @@ type SubscriptionType string
 const (
 	DAILY  SubscriptionType = "daily"
 	HOURLY SubscriptionType = "hourly"
+	ALERT  SubscriptionType = "alert"
 )
 
+// SubscriptionTypes are supported subscription frequencies
+var SubscriptionTypes = []SubscriptionType{HOURLY, DAILY, ALERT}
+
+// Supported reports whether subscriptions of the type are delivered
+func (t SubscriptionType) Supported() bool {
+	for _, supported := range SubscriptionTypes {
+		if t == supported {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SubscriptionTypeNames returns supported frequencies as strings
+func SubscriptionTypeNames() []string {
+	names := make([]string, 0, len(SubscriptionTypes))
+	for _, subscriptionType := range SubscriptionTypes {
+		names = append(names, string(subscriptionType))
+	}
+
+	return names
+}
+
 type TokenType string
 
 const (
 	Sub   TokenType = "subscribe"
 	Unsub TokenType = "unsubscribe"
+	// Manage token lets subscriber view and change the subscription
+	Manage TokenType = "manage"
 )
@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// Suppression is an address emails are no longer sent to, Email is stored lowercase
+type Suppression struct {
+	Email     string `gorm:"primaryKey;text"`
+	Reason    string `gorm:"text;not null"`
+	Source    string `gorm:"text;not null"`
+	Detail    string `gorm:"text"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+type SuppressionReason string
+
+const (
+	SuppressedBounce    SuppressionReason = "bounce"
+	SuppressedComplaint SuppressionReason = "complaint"
+	SuppressedManual    SuppressionReason = "manual"
+)
@@ -6,11 +6,10 @@ import (
 )
 
 type Token struct {
-	Token            string    `gorm:"primaryKey;default:uuid_generate_v4()"`
-	Type             string    `gorm:"not null;text;uniqueIndex:uni_user_id_token_type"`
-	SubscriptionType string    `gorm:"text"`
-	ExpiryAt         time.Time `gorm:"not null;check:expiry_at > now()"`
-	UserID           string    `gorm:"not null;text;uniqueIndex:uni_user_id_token_type;"`
-	User             User      `gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
-	DeletedAt        gorm.DeletedAt
+	Token          string       `gorm:"primaryKey;default:uuid_generate_v4()"`
+	Type           string       `gorm:"not null;text;uniqueIndex:uni_subscription_id_token_type,where:deleted_at IS NULL"`
+	ExpiryAt       time.Time    `gorm:"not null;check:expiry_at > now()"`
+	SubscriptionID string       `gorm:"not null;text;uniqueIndex:uni_subscription_id_token_type,where:deleted_at IS NULL;"`
+	Subscription   Subscription `gorm:"foreignKey:SubscriptionID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	DeletedAt      gorm.DeletedAt
 }
@@ -0,0 +1,107 @@
+package models
+
+// Units is a measurement system weather values are expressed in
+type Units string
+
+const (
+	// Metric values are degrees Celsius, km/h, mm and km
+	Metric Units = "metric"
+	// Imperial values are degrees Fahrenheit, mph, inches and miles
+	Imperial Units = "imperial"
+)
+
+// DefaultLanguage is used when subscriber did not choose a language
+const DefaultLanguage = "en"
+
+const (
+	kilometersInMile  = 1.609344
+	millimetersInInch = 25.4
+)
+
+// InUnits returns copy of the weather with values converted to units
+func (w *Weather) InUnits(units Units) *Weather {
+	converted := *w
+	if w.UnitsSystem() == units {
+		return &converted
+	}
+
+	convert := converter(units)
+	converted.Units = string(units)
+	converted.Temperature = convert.temperature(w.Temperature)
+	converted.WindSpeed = convert.distance(w.WindSpeed)
+	converted.WindGust = convert.distance(w.WindGust)
+	converted.Precipitation = convert.precipitation(w.Precipitation)
+	converted.Visibility = convert.distance(w.Visibility)
+	converted.History.TemperatureChange = convert.temperatureChange(w.History.TemperatureChange)
+	converted.History.MaxTemperature = convert.temperature(w.History.MaxTemperature)
+	converted.History.MinTemperature = convert.temperature(w.History.MinTemperature)
+	converted.History.Precipitation = convert.precipitation(w.History.Precipitation)
+
+	return &converted
+}
+
+// UnitsSystem returns units of the weather, records stored before units were introduced are metric
+func (w *Weather) UnitsSystem() Units {
+	if w.Units == "" {
+		return Metric
+	}
+	return Units(w.Units)
+}
+
+// InUnits returns copy of the forecast with values converted to units
+func (f *Forecast) InUnits(units Units) *Forecast {
+	converted := *f
+	if f.UnitsSystem() == units {
+		return &converted
+	}
+
+	convert := converter(units)
+	converted.Units = string(units)
+	converted.Days = make([]ForecastDay, len(f.Days))
+	for i, day := range f.Days {
+		day.MaxTemperature = convert.temperature(day.MaxTemperature)
+		day.MinTemperature = convert.temperature(day.MinTemperature)
+		converted.Days[i] = day
+	}
+	converted.Hours = make([]ForecastHour, len(f.Hours))
+	for i, hour := range f.Hours {
+		hour.Temperature = convert.temperature(hour.Temperature)
+		converted.Hours[i] = hour
+	}
+
+	return &converted
+}
+
+// UnitsSystem returns units of the forecast
+func (f *Forecast) UnitsSystem() Units {
+	if f.Units == "" {
+		return Metric
+	}
+	return Units(f.Units)
+}
+
+type unitConverter struct {
+	temperature       func(value float64) float64
+	temperatureChange func(value float64) float64
+	distance          func(value float64) float64
+	precipitation     func(value float64) float64
+}
+
+// converter returns conversions of metric values to imperial or the other way round
+func converter(to Units) unitConverter {
+	if to == Imperial {
+		return unitConverter{
+			temperature:       func(value float64) float64 { return value*9/5 + 32 },
+			temperatureChange: func(value float64) float64 { return value * 9 / 5 },
+			distance:          func(value float64) float64 { return value / kilometersInMile },
+			precipitation:     func(value float64) float64 { return value / millimetersInInch },
+		}
+	}
+
+	return unitConverter{
+		temperature:       func(value float64) float64 { return (value - 32) * 5 / 9 },
+		temperatureChange: func(value float64) float64 { return value * 5 / 9 },
+		distance:          func(value float64) float64 { return value * kilometersInMile },
+		precipitation:     func(value float64) float64 { return value * millimetersInInch },
+	}
+}
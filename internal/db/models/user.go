@@ -1,8 +1,9 @@
 package models
 
 type User struct {
-	ID     string `gorm:"primaryKey;default:uuid_generate_v4()"`
-	Email  string `gorm:"not null;unique"`
-	CityID string `gorm:"not null;foreignKey:CityID"`
-	City   City   `gorm:"foreignKey:CityID"`
+	ID            string         `gorm:"primaryKey;default:uuid_generate_v4()"`
+	Email         string         `gorm:"not null;unique"`
+	Units         string         `gorm:"text;not null;default:'metric'"`
+	Language      string         `gorm:"text;not null;default:'en'"`
+	Subscriptions []Subscription `gorm:"foreignKey:UserID"`
 }
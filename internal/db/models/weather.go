@@ -2,12 +2,41 @@ package models
 
 import "time"
 
+// Weather is current conditions of a city. Metric values are km/h, mm and km, imperial are mph, inches
+// and miles. Pressure is in millibars for both. Description is in Language, while Condition is in English
 type Weather struct {
-	ID          string    `gorm:"primaryKey;default:uuid_generate_v4()"`
-	Time        time.Time `gorm:"not null"`
-	Temperature float64   `gorm:"not null"`
-	Humidity    int       `gorm:"not null"`
-	Description string    `gorm:"not null"`
-	CityID      string    `gorm:"not null"`
-	City        City      `gorm:"foreignKey:CityID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	ID                       string    `gorm:"primaryKey;default:uuid_generate_v4()"`
+	Time                     time.Time `gorm:"not null"`
+	Temperature              float64   `gorm:"not null"`
+	Humidity                 int       `gorm:"not null"`
+	Description              string    `gorm:"not null"`
+	Condition                string    `gorm:"text;not null;default:''"`
+	WindSpeed                float64   `gorm:"not null;default:0"`
+	WindGust                 float64   `gorm:"not null;default:0"`
+	WindDirection            int       `gorm:"not null;default:0"`
+	WindCardinal             string    `gorm:"text"`
+	PrecipitationProbability int       `gorm:"not null;default:0"`
+	PrecipitationType        string    `gorm:"text"`
+	Precipitation            float64   `gorm:"not null;default:0"`
+	ThunderstormProbability  int       `gorm:"not null;default:0"`
+	UVIndex                  int       `gorm:"column:uv_index;not null;default:0"`
+	Pressure                 float64   `gorm:"not null;default:0"`
+	Visibility               float64   `gorm:"not null;default:0"`
+	CloudCover               int       `gorm:"not null;default:0"`
+	// History is a summary of the last 24 hours, it is empty if provider does not report it
+	History  WeatherHistory `gorm:"embedded;embeddedPrefix:history_"`
+	Units    string         `gorm:"text;not null;default:'metric'"`
+	Language string         `gorm:"text;not null;default:'en'"`
+	Provider string         `gorm:"text"`
+	CityID   string         `gorm:"not null"`
+	City     City           `gorm:"foreignKey:CityID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+}
+
+type WeatherHistory struct {
+	TemperatureChange float64
+	MaxTemperature    float64
+	MinTemperature    float64
+	Precipitation     float64
+	// Available is false when provider does not report history
+	Available bool `gorm:"not null;default:false"`
 }
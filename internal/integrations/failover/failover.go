@@ -0,0 +1,187 @@
+package failover
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"sync"
+	"time"
+	"weather-subscriptions/internal/db/models"
+	"weather-subscriptions/internal/integrations"
+)
+
+// clock returns the current time of circuits, tests replace it to pass the cooldown
+var clock = time.Now
+
+// Provider is a named integration participating in failover chain
+type Provider struct {
+	Name        string
+	Integration integrations.MapsIntegration
+}
+
+// Failover integration calls providers in order and falls through to the next one on failure.
+// Provider which failed threshold times in a row is skipped until cooldown passes,
+// after that a single trial call decides whether it is healthy again
+type Failover struct {
+	providers []*provider
+	threshold int
+	cooldown  time.Duration
+}
+
+type provider struct {
+	Provider
+	mu       sync.Mutex
+	failures int
+	openedAt time.Time
+	trial    bool
+}
+
+func New(threshold int, cooldown time.Duration, providers ...Provider) integrations.MapsIntegration {
+	chain := make([]*provider, 0, len(providers))
+	for _, p := range providers {
+		chain = append(chain, &provider{Provider: p})
+	}
+
+	return &Failover{
+		providers: chain,
+		threshold: threshold,
+		cooldown:  cooldown,
+	}
+}
+
+func (f *Failover) GetWeather(ctx context.Context, city *models.City) (*models.Weather, error) {
+	var weather *models.Weather
+	err := f.call(ctx, func(p *provider) (err error) {
+		weather, err = p.Integration.GetWeather(ctx, city)
+		if err == nil && weather.Provider == "" {
+			weather.Provider = p.Name
+		}
+		return err
+	})
+
+	return weather, err
+}
+
+func (f *Failover) GetForecast(ctx context.Context, city *models.City, days int) (*models.Forecast, error) {
+	var forecast *models.Forecast
+	err := f.call(ctx, func(p *provider) (err error) {
+		forecast, err = p.Integration.GetForecast(ctx, city, days)
+		if err == nil && forecast.Provider == "" {
+			forecast.Provider = p.Name
+		}
+		return err
+	})
+
+	return forecast, err
+}
+
+func (f *Failover) GetCities(ctx context.Context, cityName string) ([]*models.City, error) {
+	var cities []*models.City
+	err := f.call(ctx, func(p *provider) (err error) {
+		cities, err = p.Integration.GetCities(ctx, cityName)
+		return err
+	})
+
+	return cities, err
+}
+
+func (f *Failover) GetCityAt(ctx context.Context, latitude, longitude float64) (*models.City, error) {
+	var city *models.City
+	err := f.call(ctx, func(p *provider) (err error) {
+		city, err = p.Integration.GetCityAt(ctx, latitude, longitude)
+		return err
+	})
+
+	return city, err
+}
+
+// call asks providers in order until one succeeds. Places which are not found and calls the caller
+// cancelled do not count as provider failures
+func (f *Failover) call(ctx context.Context, fn func(p *provider) error) error {
+	var errs []error
+	for _, p := range f.providers {
+		if !p.allow(f.cooldown) {
+			errs = append(errs, fmt.Errorf("%s: circuit open", p.Name))
+			continue
+		}
+
+		err := fn(p)
+		if err == nil {
+			p.succeed()
+			return nil
+		}
+		// provider lacking the operation is healthy, the next one is asked
+		if errors.Is(err, integrations.ErrUnsupported) {
+			p.release()
+			errs = append(errs, fmt.Errorf("%s: %w", p.Name, err))
+			continue
+		}
+		// the provider answered, the next one may know the place
+		if errors.Is(err, integrations.ErrNotFound) {
+			p.succeed()
+			errs = append(errs, fmt.Errorf("%s: %w", p.Name, err))
+			continue
+		}
+		if ctx.Err() != nil {
+			p.release()
+			errs = append(errs, fmt.Errorf("%s: %w", p.Name, err))
+			return errors.Join(errs...)
+		}
+		if p.fail(f.threshold) {
+			zap.L().Warn("weather provider circuit opened", zap.String("provider", p.Name), zap.Error(err))
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", p.Name, err))
+	}
+
+	return errors.Join(errs...)
+}
+
+// allow reports whether provider can be called, open circuit lets a single trial call through after cooldown
+func (p *provider) allow(cooldown time.Duration) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.openedAt.IsZero() {
+		return true
+	}
+	if p.trial || clock().Sub(p.openedAt) < cooldown {
+		return false
+	}
+	p.trial = true
+
+	return true
+}
+
+func (p *provider) succeed() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.failures = 0
+	p.openedAt = time.Time{}
+	p.trial = false
+}
+
+// release ends a trial call which neither succeeded nor failed, so the next call is a trial again
+func (p *provider) release() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.trial = false
+}
+
+// fail records failure and reports whether it opened the circuit
+func (p *provider) fail(threshold int) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.failures++
+	if p.trial || p.failures >= threshold {
+		opened := p.openedAt.IsZero()
+		p.openedAt = clock()
+		p.trial = false
+		return opened
+	}
+
+	return false
+}
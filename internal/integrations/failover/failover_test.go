@@ -0,0 +1,241 @@
+package failover
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+	"time"
+	"weather-subscriptions/internal/db/models"
+	"weather-subscriptions/internal/integrations"
+)
+
+var errUnavailable = errors.New("service unavailable")
+
+// stub is a provider answering every call with err, calls block while block is set until it is closed
+type stub struct {
+	mu       sync.Mutex
+	err      error
+	provider string
+	calls    int
+	block    chan struct{}
+	started  chan struct{}
+}
+
+func (s *stub) answer(ctx context.Context) error {
+	s.mu.Lock()
+	s.calls++
+	err, block, started := s.err, s.block, s.started
+	s.mu.Unlock()
+
+	if block != nil {
+		started <- struct{}{}
+		<-block
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	return err
+}
+
+func (s *stub) set(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.err = err
+}
+
+func (s *stub) callCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.calls
+}
+
+func (s *stub) GetWeather(ctx context.Context, city *models.City) (*models.Weather, error) {
+	if err := s.answer(ctx); err != nil {
+		return nil, err
+	}
+
+	return &models.Weather{CityID: city.ID, Provider: s.provider}, nil
+}
+
+func (s *stub) GetForecast(ctx context.Context, city *models.City, days int) (*models.Forecast, error) {
+	if err := s.answer(ctx); err != nil {
+		return nil, err
+	}
+
+	return &models.Forecast{CityID: city.ID, Provider: s.provider}, nil
+}
+
+func (s *stub) GetCities(ctx context.Context, cityName string) ([]*models.City, error) {
+	if err := s.answer(ctx); err != nil {
+		return nil, err
+	}
+
+	return []*models.City{{Name: cityName}}, nil
+}
+
+func (s *stub) GetCityAt(ctx context.Context, latitude, longitude float64) (*models.City, error) {
+	if err := s.answer(ctx); err != nil {
+		return nil, err
+	}
+
+	return &models.City{Latitude: latitude, Longitude: longitude}, nil
+}
+
+func newTestFailover(threshold int, cooldown time.Duration, primary, secondary *stub) integrations.MapsIntegration {
+	return New(threshold, cooldown,
+		Provider{Name: "primary", Integration: primary},
+		Provider{Name: "secondary", Integration: secondary},
+	)
+}
+
+func TestCountedFailures(t *testing.T) {
+	const threshold = 2
+	tests := []struct {
+		name   string
+		err    error
+		cancel bool
+		opens  bool
+	}{
+		{name: "provider failure", err: errUnavailable, opens: true},
+		{name: "place not found", err: integrations.ErrNotFound},
+		{name: "unsupported operation", err: integrations.ErrUnsupported},
+		{name: "cancelled call", err: errUnavailable, cancel: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			primary, secondary := &stub{err: test.err}, &stub{}
+			failover := newTestFailover(threshold, time.Hour, primary, secondary)
+			ctx, cancel := context.WithCancel(context.Background())
+			if test.cancel {
+				cancel()
+			}
+			defer cancel()
+
+			for range threshold + 1 {
+				_, _ = failover.GetCities(ctx, "kyiv")
+			}
+
+			if test.opens {
+				assert.Equal(t, threshold, primary.callCount(), "open circuit skips the provider")
+			} else {
+				assert.Equal(t, threshold+1, primary.callCount(), "provider is still called")
+			}
+			if test.cancel {
+				assert.Zero(t, secondary.callCount(), "cancelled call does not fall through")
+			} else {
+				assert.Equal(t, threshold+1, secondary.callCount(), "call falls through to the next provider")
+			}
+		})
+	}
+}
+
+func TestFallsThroughToNextProvider(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		provider string
+		want     string
+	}{
+		{name: "provider failure", err: errUnavailable, want: "secondary"},
+		{name: "place not found", err: integrations.ErrNotFound, want: "secondary"},
+		{name: "unsupported operation", err: integrations.ErrUnsupported, want: "secondary"},
+		{name: "provenance set by provider", err: errUnavailable, provider: "secondary-v2", want: "secondary-v2"},
+		{name: "healthy primary", want: "primary"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			failover := newTestFailover(3, time.Hour, &stub{err: test.err}, &stub{provider: test.provider})
+			city := &models.City{ID: "city-id"}
+
+			weather, err := failover.GetWeather(context.Background(), city)
+			require.NoError(t, err)
+			assert.Equal(t, test.want, weather.Provider)
+
+			forecast, err := failover.GetForecast(context.Background(), city, 3)
+			require.NoError(t, err)
+			assert.Equal(t, test.want, forecast.Provider)
+		})
+	}
+}
+
+func TestAllProvidersFail(t *testing.T) {
+	failover := newTestFailover(1, time.Hour, &stub{err: errUnavailable}, &stub{err: integrations.ErrNotFound})
+
+	_, err := failover.GetCityAt(context.Background(), 50.45, 30.52)
+	assert.ErrorIs(t, err, errUnavailable)
+	assert.ErrorIs(t, err, integrations.ErrNotFound)
+
+	_, err = failover.GetCityAt(context.Background(), 50.45, 30.52)
+	assert.ErrorContains(t, err, "primary: circuit open")
+}
+
+func TestTrialCallAfterCooldown(t *testing.T) {
+	tests := []struct {
+		name     string
+		trialErr error
+		closes   bool
+	}{
+		{name: "successful trial closes circuit", closes: true},
+		{name: "not found trial closes circuit", trialErr: integrations.ErrNotFound, closes: true},
+		{name: "failed trial opens circuit again", trialErr: errUnavailable},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			defer func(previous func() time.Time) { clock = previous }(clock)
+			now := time.Date(2025, time.June, 14, 7, 30, 0, 0, time.UTC)
+			clock = func() time.Time { return now }
+
+			primary, secondary := &stub{err: errUnavailable}, &stub{}
+			failover := newTestFailover(3, time.Minute, primary, secondary)
+			for range 4 {
+				_, err := failover.GetCities(context.Background(), "kyiv")
+				require.NoError(t, err)
+			}
+			require.Equal(t, 3, primary.callCount(), "open circuit skips the provider until cooldown passes")
+
+			now = now.Add(time.Minute)
+			primary.mu.Lock()
+			primary.err = test.trialErr
+			primary.block = make(chan struct{})
+			primary.started = make(chan struct{})
+			block := primary.block
+			started := primary.started
+			primary.mu.Unlock()
+
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				_, _ = failover.GetCities(context.Background(), "kyiv")
+			}()
+			<-started
+			_, err := failover.GetCities(context.Background(), "kyiv")
+			require.NoError(t, err)
+			assert.Equal(t, 4, primary.callCount(), "only one trial call is let through")
+
+			primary.mu.Lock()
+			primary.block = nil
+			primary.mu.Unlock()
+			close(block)
+			<-done
+
+			primary.set(errUnavailable)
+			for range 3 {
+				_, _ = failover.GetCities(context.Background(), "kyiv")
+			}
+			if test.closes {
+				assert.Equal(t, 4+3, primary.callCount(), "closed circuit calls the provider")
+			} else {
+				assert.Equal(t, 4, primary.callCount(), "reopened circuit waits for the next cooldown")
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package google
+
+import (
+	"context"
+	"errors"
+	"github.com/go-resty/resty/v2"
+	"github.com/google/uuid"
+	"strconv"
+	"time"
+	"weather-subscriptions/internal/db/models"
+	"weather-subscriptions/internal/integrations"
+)
+
+const (
+	forecastDaysURL  = "https://weather.googleapis.com/v1/forecast/days:lookup"
+	forecastHoursURL = "https://weather.googleapis.com/v1/forecast/hours:lookup"
+)
+
+// fetchForecastForCity requests daily and hourly forecasts, page size is set to get them in a single page
+func (g *Google) fetchForecastForCity(ctx context.Context, city *models.City, days int) (*models.Forecast, error) {
+	client := resty.New()
+	preferences := integrations.PreferencesFrom(ctx)
+
+	var daysResult ForecastDaysResponse
+	req, err := client.R().
+		SetContext(ctx).
+		SetResult(&daysResult).
+		Get(forecastDaysURL + "?" + g.getForecastQuery(city, preferences, "days", days))
+	if err != nil {
+		return nil, err
+	}
+	if !req.IsSuccess() {
+		return nil, errors.New("could not fetch daily forecast for city: " + city.Name)
+	}
+
+	var hoursResult ForecastHoursResponse
+	req, err = client.R().
+		SetContext(ctx).
+		SetResult(&hoursResult).
+		Get(forecastHoursURL + "?" + g.getForecastQuery(city, preferences, "hours", integrations.ForecastHours))
+	if err != nil {
+		return nil, err
+	}
+	if !req.IsSuccess() {
+		return nil, errors.New("could not fetch hourly forecast for city: " + city.Name)
+	}
+	if city.TimeZone == "" {
+		city.TimeZone = daysResult.TimeZone.Id
+	}
+
+	forecast := &models.Forecast{
+		ID:       uuid.Must(uuid.NewV7()).String(),
+		Time:     time.Now(),
+		Units:    string(preferences.Units),
+		Language: preferences.Language,
+		Provider: providerName,
+		CityID:   city.ID,
+		City:     *city,
+	}
+	for _, day := range daysResult.ForecastDays {
+		forecast.Days = append(forecast.Days, models.ForecastDay{
+			ID:             uuid.Must(uuid.NewV7()).String(),
+			ForecastID:     forecast.ID,
+			Date:           time.Date(day.DisplayDate.Year, time.Month(day.DisplayDate.Month), day.DisplayDate.Day, 0, 0, 0, 0, time.UTC),
+			MaxTemperature: day.MaxTemperature.Degrees,
+			MinTemperature: day.MinTemperature.Degrees,
+			PrecipitationProbability: max(
+				day.DaytimeForecast.Precipitation.Probability.Percent,
+				day.NighttimeForecast.Precipitation.Probability.Percent,
+			),
+			Description: day.DaytimeForecast.WeatherCondition.Description.Text,
+		})
+	}
+	for _, hour := range hoursResult.ForecastHours {
+		forecast.Hours = append(forecast.Hours, models.ForecastHour{
+			ID:                       uuid.Must(uuid.NewV7()).String(),
+			ForecastID:               forecast.ID,
+			Time:                     hour.Interval.StartTime,
+			Temperature:              hour.Temperature.Degrees,
+			Humidity:                 hour.RelativeHumidity,
+			PrecipitationProbability: hour.Precipitation.Probability.Percent,
+			Description:              hour.WeatherCondition.Description.Text,
+		})
+	}
+
+	return forecast, nil
+}
+
+// getForecastQuery builds query for days or hours forecast lookup
+func (g *Google) getForecastQuery(city *models.City, preferences integrations.Preferences, period string, count int) string {
+	query := g.getQuery(city, preferences)
+	return query + "&" + period + "=" + strconv.Itoa(count) + "&pageSize=" + strconv.Itoa(count)
+}
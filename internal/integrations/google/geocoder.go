@@ -4,21 +4,81 @@ import (
 	"context"
 	"fmt"
 	"googlemaps.github.io/maps"
+	"slices"
+	"time"
+	"weather-subscriptions/internal/integrations"
 )
 
-func fetchCityInfo(ctx context.Context, client *maps.Client, cityName string) (*CityInfo, error) {
+// placeTypes are result types coordinates are reverse geocoded to, from a city to a wider area
+var placeTypes = []string{"locality", "postal_town", "administrative_area_level_3", "administrative_area_level_2"}
+
+func fetchCityInfos(ctx context.Context, client *maps.Client, cityName string) ([]*CityInfo, error) {
 	responses, err := client.Geocode(ctx, &maps.GeocodingRequest{Address: cityName})
 	if err != nil {
 		return nil, err
 	}
 
 	if len(responses) == 0 {
-		return nil, fmt.Errorf("no results found for city with name: '%s'", cityName)
+		return nil, fmt.Errorf("%w: no results found for city with name: '%s'", integrations.ErrNotFound, cityName)
+	}
+
+	cityInfos := make([]*CityInfo, 0, min(len(responses), integrations.MaxCities))
+	for _, response := range responses[:cap(cityInfos)] {
+		cityInfos = append(cityInfos, newCityInfo(response))
+	}
+
+	return cityInfos, nil
+}
+
+func fetchCityInfoAt(ctx context.Context, client *maps.Client, latitude, longitude float64) (*CityInfo, error) {
+	responses, err := client.ReverseGeocode(ctx, &maps.GeocodingRequest{
+		LatLng:     &maps.LatLng{Lat: latitude, Lng: longitude},
+		ResultType: placeTypes,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(responses) == 0 {
+		return nil, fmt.Errorf("%w: no results found for coordinates: %f,%f", integrations.ErrNotFound, latitude, longitude)
+	}
+
+	return newCityInfo(responses[0]), nil
+}
+
+// newCityInfo returns place of the geocoding result, the first component of a locality or an area
+// result is its name
+func newCityInfo(result maps.GeocodingResult) *CityInfo {
+	cityInfo := &CityInfo{
+		Name:          result.FormattedAddress,
+		GooglePlaceID: result.PlaceID,
+		Latitude:      result.Geometry.Location.Lat,
+		Longitude:     result.Geometry.Location.Lng,
+	}
+	for i, component := range result.AddressComponents {
+		if i == 0 {
+			cityInfo.Name = component.LongName
+		}
+		switch {
+		case slices.Contains(component.Types, "country"):
+			cityInfo.Country = component.LongName
+		case slices.Contains(component.Types, "administrative_area_level_1"):
+			cityInfo.Region = component.LongName
+		}
+	}
+
+	return cityInfo
+}
+
+// fetchTimeZone returns IANA time zone of the coordinates
+func fetchTimeZone(ctx context.Context, client *maps.Client, latitude, longitude float64) (string, error) {
+	result, err := client.Timezone(ctx, &maps.TimezoneRequest{
+		Location:  &maps.LatLng{Lat: latitude, Lng: longitude},
+		Timestamp: time.Now(),
+	})
+	if err != nil {
+		return "", err
 	}
 
-	return &CityInfo{
-		GooglePlaceID: responses[0].PlaceID,
-		Latitude:      responses[0].Geometry.Location.Lat,
-		Longitude:     responses[0].Geometry.Location.Lng,
-	}, nil
+	return result.TimeZoneID, nil
 }
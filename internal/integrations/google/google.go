@@ -3,6 +3,7 @@ package google
 import (
 	"context"
 	"github.com/google/uuid"
+	"github.com/gosimple/slug"
 	"go.uber.org/zap"
 	"googlemaps.github.io/maps"
 	"weather-subscriptions/internal/config"
@@ -30,29 +31,75 @@ func (g *Google) GetWeather(ctx context.Context, city *models.City) (*models.Wea
 	return weather, nil
 }
 
-func (g *Google) GetCity(ctx context.Context, cityName string) (*models.City, error) {
+func (g *Google) GetForecast(ctx context.Context, city *models.City, days int) (*models.Forecast, error) {
+	forecast, err := g.fetchForecastForCity(ctx, city, days)
+	if err != nil {
+		zap.L().Error("failed to fetch forecast", zap.Error(err))
+		return nil, err
+	}
+
+	return forecast, nil
+}
+
+func (g *Google) GetCities(ctx context.Context, cityName string) ([]*models.City, error) {
+	mapsClient, err := maps.NewClient(maps.WithAPIKey(g.cfg.GoogleMapsApiKey))
+	if err != nil {
+		zap.L().Error("failed to create maps client", zap.Error(err))
+		return nil, err
+	}
+	return getCities(ctx, mapsClient, cityName)
+}
+
+func (g *Google) GetCityAt(ctx context.Context, latitude, longitude float64) (*models.City, error) {
 	mapsClient, err := maps.NewClient(maps.WithAPIKey(g.cfg.GoogleMapsApiKey))
 	if err != nil {
 		zap.L().Error("failed to create maps client", zap.Error(err))
 		return nil, err
 	}
-	return getCity(ctx, mapsClient, cityName)
+	return getCityAt(ctx, mapsClient, latitude, longitude)
+}
+
+func getCities(ctx context.Context, client *maps.Client, cityName string) ([]*models.City, error) {
+	cityInfos, err := fetchCityInfos(ctx, client, cityName)
+	if err != nil {
+		zap.L().Error("failed to fetch city info", zap.Error(err))
+		return nil, err
+	}
+
+	cities := make([]*models.City, 0, len(cityInfos))
+	for _, cityInfo := range cityInfos {
+		cities = append(cities, newCity(ctx, client, cityInfo))
+	}
+
+	return cities, nil
 }
 
-func getCity(ctx context.Context, client *maps.Client, cityName string) (*models.City, error) {
-	cityInfo, err := fetchCityInfo(ctx, client, cityName)
+func getCityAt(ctx context.Context, client *maps.Client, latitude, longitude float64) (*models.City, error) {
+	cityInfo, err := fetchCityInfoAt(ctx, client, latitude, longitude)
 	if err != nil {
 		zap.L().Error("failed to fetch city info", zap.Error(err))
 		return nil, err
 	}
 
-	city := &models.City{
+	return newCity(ctx, client, cityInfo), nil
+}
+
+// newCity returns city of the place, its time zone is left empty when it could not be fetched
+func newCity(ctx context.Context, client *maps.Client, cityInfo *CityInfo) *models.City {
+	timeZone, err := fetchTimeZone(ctx, client, cityInfo.Latitude, cityInfo.Longitude)
+	if err != nil {
+		zap.L().Warn("failed to fetch city time zone", zap.Error(err))
+	}
+
+	return &models.City{
 		ID:            uuid.Must(uuid.NewV7()).String(),
-		Name:          cityName,
+		Name:          slug.Make(cityInfo.Name),
+		FormattedName: integrations.FormatName(cityInfo.Name, cityInfo.Region, cityInfo.Country),
+		Region:        cityInfo.Region,
+		Country:       cityInfo.Country,
 		Latitude:      cityInfo.Latitude,
 		Longitude:     cityInfo.Longitude,
 		GooglePlaceID: cityInfo.GooglePlaceID,
+		TimeZone:      timeZone,
 	}
-
-	return city, nil
 }
@@ -7,75 +7,124 @@ type WeatherResponse struct {
 	TimeZone    struct {
 		Id string `json:"id"`
 	} `json:"timeZone"`
-	//IsDaytime        bool `json:"isDaytime"`
+	IsDaytime        bool `json:"isDaytime"`
 	WeatherCondition struct {
-		IconBaseUri string   `json:"iconBaseUri"`
-		Description struct { // ++
+		IconBaseUri string `json:"iconBaseUri"`
+		Description struct {
 			Text         string `json:"text"`
 			LanguageCode string `json:"languageCode"`
 		} `json:"description"`
 		Type string `json:"type"`
 	} `json:"weatherCondition"`
-	Temperature struct { // ++
-		Degrees float64 `json:"degrees"`
-		Unit    string  `json:"unit"`
-	} `json:"temperature"`
-	RelativeHumidity int `json:"relativeHumidity"` // ++
-	//UvIndex          int `json:"uvIndex"`
-	//Precipitation    struct {
-	//	Probability struct {
-	//		Percent int    `json:"percent"`
-	//		Type    string `json:"type"`
-	//	} `json:"probability"`
-	//	Qpf struct {
-	//		Quantity float64 `json:"quantity"`
-	//		Unit     string  `json:"unit"`
-	//	} `json:"qpf"`
-	//} `json:"precipitation"`
-	//ThunderstormProbability int `json:"thunderstormProbability"`
-	//AirPressure             struct {
-	//	MeanSeaLevelMillibars float64 `json:"meanSeaLevelMillibars"`
-	//} `json:"airPressure"`
-	//Wind struct {
-	//	Direction struct {
-	//		Degrees  int    `json:"degrees"`
-	//		Cardinal string `json:"cardinal"`
-	//	} `json:"direction"`
-	//	Speed struct {
-	//		Value int    `json:"value"`
-	//		Unit  string `json:"unit"`
-	//	} `json:"speed"`
-	//	Gust struct {
-	//		Value int    `json:"value"`
-	//		Unit  string `json:"unit"`
-	//	} `json:"gust"`
-	//} `json:"wind"`
-	//Visibility struct {
-	//	Distance int    `json:"distance"`
-	//	Unit     string `json:"unit"`
-	//} `json:"visibility"`
-	//CloudCover               int `json:"cloudCover"`
-	//CurrentConditionsHistory struct {
-	//	TemperatureChange struct {
-	//		Degrees float64 `json:"degrees"`
-	//		Unit    string  `json:"unit"`
-	//	} `json:"temperatureChange"`
-	//	MaxTemperature struct {
-	//		Degrees float64 `json:"degrees"`
-	//		Unit    string  `json:"unit"`
-	//	} `json:"maxTemperature"`
-	//	MinTemperature struct {
-	//		Degrees float64 `json:"degrees"`
-	//		Unit    string  `json:"unit"`
-	//	} `json:"minTemperature"`
-	//	Qpf struct {
-	//		Quantity int    `json:"quantity"`
-	//		Unit     string `json:"unit"`
-	//	} `json:"qpf"`
-	//} `json:"currentConditionsHistory"`
+	Temperature      Temperature `json:"temperature"`
+	RelativeHumidity int         `json:"relativeHumidity"`
+	UvIndex          int         `json:"uvIndex"`
+	Precipitation    struct {
+		Probability struct {
+			Percent int    `json:"percent"`
+			Type    string `json:"type"`
+		} `json:"probability"`
+		Qpf Quantity `json:"qpf"`
+	} `json:"precipitation"`
+	ThunderstormProbability int `json:"thunderstormProbability"`
+	AirPressure             struct {
+		MeanSeaLevelMillibars float64 `json:"meanSeaLevelMillibars"`
+	} `json:"airPressure"`
+	Wind struct {
+		Direction struct {
+			Degrees  int    `json:"degrees"`
+			Cardinal string `json:"cardinal"`
+		} `json:"direction"`
+		Speed Speed `json:"speed"`
+		Gust  Speed `json:"gust"`
+	} `json:"wind"`
+	Visibility struct {
+		Distance float64 `json:"distance"`
+		Unit     string  `json:"unit"`
+	} `json:"visibility"`
+	CloudCover               int `json:"cloudCover"`
+	CurrentConditionsHistory struct {
+		TemperatureChange Temperature `json:"temperatureChange"`
+		MaxTemperature    Temperature `json:"maxTemperature"`
+		MinTemperature    Temperature `json:"minTemperature"`
+		Qpf               Quantity    `json:"qpf"`
+	} `json:"currentConditionsHistory"`
+}
+
+type Temperature struct {
+	Degrees float64 `json:"degrees"`
+	Unit    string  `json:"unit"`
+}
+
+type Speed struct {
+	Value float64 `json:"value"`
+	Unit  string  `json:"unit"`
+}
+
+// Quantity is an amount of precipitation
+type Quantity struct {
+	Quantity float64 `json:"quantity"`
+	Unit     string  `json:"unit"`
+}
+
+type ForecastDaysResponse struct {
+	ForecastDays []struct {
+		DisplayDate struct {
+			Year  int `json:"year"`
+			Month int `json:"month"`
+			Day   int `json:"day"`
+		} `json:"displayDate"`
+		DaytimeForecast   ForecastPeriod `json:"daytimeForecast"`
+		NighttimeForecast ForecastPeriod `json:"nighttimeForecast"`
+		MaxTemperature    Temperature    `json:"maxTemperature"`
+		MinTemperature    Temperature    `json:"minTemperature"`
+	} `json:"forecastDays"`
+	TimeZone struct {
+		Id string `json:"id"`
+	} `json:"timeZone"`
+}
+
+// ForecastPeriod is a daytime or nighttime part of a forecast day
+type ForecastPeriod struct {
+	WeatherCondition struct {
+		Description struct {
+			Text string `json:"text"`
+		} `json:"description"`
+	} `json:"weatherCondition"`
+	RelativeHumidity int `json:"relativeHumidity"`
+	Precipitation    struct {
+		Probability struct {
+			Percent int    `json:"percent"`
+			Type    string `json:"type"`
+		} `json:"probability"`
+	} `json:"precipitation"`
+}
+
+type ForecastHoursResponse struct {
+	ForecastHours []struct {
+		Interval struct {
+			StartTime time.Time `json:"startTime"`
+		} `json:"interval"`
+		WeatherCondition struct {
+			Description struct {
+				Text string `json:"text"`
+			} `json:"description"`
+		} `json:"weatherCondition"`
+		Temperature      Temperature `json:"temperature"`
+		RelativeHumidity int         `json:"relativeHumidity"`
+		Precipitation    struct {
+			Probability struct {
+				Percent int    `json:"percent"`
+				Type    string `json:"type"`
+			} `json:"probability"`
+		} `json:"precipitation"`
+	} `json:"forecastHours"`
 }
 
 type CityInfo struct {
+	Name          string  `json:"name"`
+	Region        string  `json:"region"`
+	Country       string  `json:"country"`
 	Latitude      float64 `json:"latitude"`
 	Longitude     float64 `json:"longitude"`
 	GooglePlaceID string  `json:"googlePlaceId"`
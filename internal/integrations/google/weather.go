@@ -6,15 +6,26 @@ import (
 	"github.com/go-resty/resty/v2"
 	"github.com/google/uuid"
 	"net/url"
+	"strings"
 	"time"
 	"weather-subscriptions/internal/db/models"
+	"weather-subscriptions/internal/integrations"
 )
 
-const weatherURL = "https://weather.googleapis.com/v1/currentConditions:lookup"
+const (
+	weatherURL   = "https://weather.googleapis.com/v1/currentConditions:lookup"
+	providerName = "google"
+)
+
+var unitsSystems = map[models.Units]string{
+	models.Metric:   "METRIC",
+	models.Imperial: "IMPERIAL",
+}
 
 func (g *Google) fetchWeatherForCity(ctx context.Context, city *models.City) (*models.Weather, error) {
 	client := resty.New()
-	query := g.getQuery(city)
+	preferences := integrations.PreferencesFrom(ctx)
+	query := g.getQuery(city, preferences)
 
 	var result WeatherResponse
 	req, err := client.R().
@@ -27,24 +38,65 @@ func (g *Google) fetchWeatherForCity(ctx context.Context, city *models.City) (*m
 	if !req.IsSuccess() {
 		return nil, errors.New("could not fetch weather for city: " + city.Name)
 	}
+	if city.TimeZone == "" {
+		city.TimeZone = result.TimeZone.Id
+	}
 
+	return newWeather(&result, city, preferences), nil
+}
+
+// newWeather maps current conditions of the city, values are in units of the preferences
+func newWeather(result *WeatherResponse, city *models.City, preferences integrations.Preferences) *models.Weather {
+	history := result.CurrentConditionsHistory
 	return &models.Weather{
-		ID:          uuid.Must(uuid.NewV7()).String(),
-		Time:        time.Now(),
-		Temperature: result.Temperature.Degrees,
-		Humidity:    result.RelativeHumidity,
-		Description: result.WeatherCondition.Description.Text,
-		CityID:      city.ID,
-		City:        *city,
-	}, nil
+		ID:                       uuid.Must(uuid.NewV7()).String(),
+		Time:                     time.Now(),
+		Temperature:              result.Temperature.Degrees,
+		Humidity:                 result.RelativeHumidity,
+		Description:              result.WeatherCondition.Description.Text,
+		Condition:                conditionName(result.WeatherCondition.Type),
+		WindSpeed:                result.Wind.Speed.Value,
+		WindGust:                 result.Wind.Gust.Value,
+		WindDirection:            result.Wind.Direction.Degrees,
+		WindCardinal:             result.Wind.Direction.Cardinal,
+		PrecipitationProbability: result.Precipitation.Probability.Percent,
+		PrecipitationType:        result.Precipitation.Probability.Type,
+		Precipitation:            result.Precipitation.Qpf.Quantity,
+		ThunderstormProbability:  result.ThunderstormProbability,
+		UVIndex:                  result.UvIndex,
+		Pressure:                 result.AirPressure.MeanSeaLevelMillibars,
+		Visibility:               result.Visibility.Distance,
+		CloudCover:               result.CloudCover,
+		History: models.WeatherHistory{
+			TemperatureChange: history.TemperatureChange.Degrees,
+			MaxTemperature:    history.MaxTemperature.Degrees,
+			MinTemperature:    history.MinTemperature.Degrees,
+			Precipitation:     history.Qpf.Quantity,
+			Available:         history.MaxTemperature.Unit != "",
+		},
+		Units:    string(preferences.Units),
+		Language: preferences.Language,
+		Provider: providerName,
+		CityID:   city.ID,
+		City:     *city,
+	}
+}
+
+// conditionName returns English name of the condition type, e.g. "light rain" of "LIGHT_RAIN"
+func conditionName(conditionType string) string {
+	return strings.ToLower(strings.ReplaceAll(conditionType, "_", " "))
 }
 
-func (g *Google) getQuery(city *models.City) string {
+// getQuery builds query of the city location, descriptions are requested in preferred language and
+// values in preferred units
+func (g *Google) getQuery(city *models.City, preferences integrations.Preferences) string {
 	query := url.Values{}
 	cityCoordinates := city.GetStringCoordinates()
 	query.Set("key", g.cfg.GoogleMapsApiKey)
 	query.Set("location.latitude", cityCoordinates.Lat)
 	query.Set("location.longitude", cityCoordinates.Long)
+	query.Set("languageCode", preferences.Language)
+	query.Set("unitsSystem", unitsSystems[preferences.Units])
 
 	return query.Encode()
 }
@@ -0,0 +1,61 @@
+package google
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+	"weather-subscriptions/internal/db/models"
+	"weather-subscriptions/internal/integrations"
+)
+
+func TestNewWeather(t *testing.T) {
+	body, err := os.ReadFile(filepath.Join("testdata", "current_conditions.json"))
+	require.NoError(t, err)
+	var result WeatherResponse
+	require.NoError(t, json.Unmarshal(body, &result))
+	city := &models.City{ID: "city-id", Name: "kyiv", Latitude: 50.45, Longitude: 30.52}
+	preferences := integrations.Preferences{Units: models.Metric, Language: "en"}
+
+	weather := newWeather(&result, city, preferences)
+
+	assert.Equal(t, 21.4, weather.Temperature)
+	assert.Equal(t, 63, weather.Humidity)
+	assert.Equal(t, "Light rain", weather.Description)
+	assert.Equal(t, "light rain", weather.Condition)
+	assert.Equal(t, 15.0, weather.WindSpeed)
+	assert.Equal(t, 31.0, weather.WindGust)
+	assert.Equal(t, 247, weather.WindDirection)
+	assert.Equal(t, "WEST_SOUTHWEST", weather.WindCardinal)
+	assert.Equal(t, 55, weather.PrecipitationProbability)
+	assert.Equal(t, "RAIN", weather.PrecipitationType)
+	assert.Equal(t, 0.4, weather.Precipitation)
+	assert.Equal(t, 10, weather.ThunderstormProbability)
+	assert.Equal(t, 4, weather.UVIndex)
+	assert.Equal(t, 1012.6, weather.Pressure)
+	assert.Equal(t, 16.0, weather.Visibility)
+	assert.Equal(t, 88, weather.CloudCover)
+	assert.Equal(t, models.WeatherHistory{
+		TemperatureChange: -1.2,
+		MaxTemperature:    24.1,
+		MinTemperature:    13.2,
+		Precipitation:     2.3,
+		Available:         true,
+	}, weather.History)
+	assert.Equal(t, string(models.Metric), weather.Units)
+	assert.Equal(t, "en", weather.Language)
+	assert.Equal(t, providerName, weather.Provider)
+	assert.Equal(t, "city-id", weather.CityID)
+	assert.NotEmpty(t, weather.ID)
+}
+
+func TestNewWeatherWithoutHistory(t *testing.T) {
+	var result WeatherResponse
+	require.NoError(t, json.Unmarshal([]byte(`{"temperature": {"degrees": 5, "unit": "CELSIUS"}}`), &result))
+
+	weather := newWeather(&result, &models.City{}, integrations.Preferences{Units: models.Metric})
+
+	assert.False(t, weather.History.Available, "history is unavailable when the response has none")
+}
@@ -2,11 +2,48 @@ package integrations
 
 import (
 	"context"
+	"errors"
+	"strings"
 	"weather-subscriptions/internal/db/models"
 )
 
-// MapsIntegration interface to all integrations which fetch data about city coordinates or current weather
+const (
+	// MaxForecastDays is the longest forecast every integration is able to fetch
+	MaxForecastDays = 10
+	// ForecastHours is a number of hourly forecasts fetched with a forecast starting from the current hour
+	ForecastHours = 24
+	// MaxCities is the most places GetCities returns for a name
+	MaxCities = 5
+)
+
+var (
+	// ErrUnsupported is returned by integrations for operations their provider does not offer
+	ErrUnsupported = errors.New("operation is not supported by the provider")
+	// ErrNotFound is returned by geocoders when no place matches, the provider itself is healthy
+	ErrNotFound = errors.New("place not found")
+)
+
+// MapsIntegration interface to all integrations which fetch data about city coordinates, current weather
+// or its forecast
 type MapsIntegration interface {
 	GetWeather(ctx context.Context, city *models.City) (*models.Weather, error)
-	GetCity(ctx context.Context, cityName string) (*models.City, error)
+	GetForecast(ctx context.Context, city *models.City, days int) (*models.Forecast, error)
+	// GetCities geocodes the name to places it may refer to, the most relevant first. Cities are named
+	// with a slug of the place name, more than one city means the name is ambiguous
+	GetCities(ctx context.Context, cityName string) ([]*models.City, error)
+	// GetCityAt reverse geocodes coordinates to the city they are in, the city has coordinates of its center
+	GetCityAt(ctx context.Context, latitude, longitude float64) (*models.City, error)
+}
+
+// FormatName joins the place name with its region and country, parts which are empty or repeat the
+// previous one are skipped, e.g. "Paris, Texas, United States" or "Singapore"
+func FormatName(name, region, country string) string {
+	parts := make([]string, 0, 3)
+	for _, part := range []string{name, region, country} {
+		if part != "" && (len(parts) == 0 || !strings.EqualFold(parts[len(parts)-1], part)) {
+			parts = append(parts, part)
+		}
+	}
+
+	return strings.Join(parts, ", ")
 }
@@ -0,0 +1,100 @@
+package openmeteo
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"net/url"
+	"strconv"
+	"time"
+	"weather-subscriptions/internal/db/models"
+	"weather-subscriptions/internal/integrations"
+)
+
+const (
+	dailyFields  = "temperature_2m_max,temperature_2m_min,precipitation_probability_max,weather_code"
+	hourlyFields = "temperature_2m,relative_humidity_2m,precipitation_probability,weather_code"
+)
+
+// fetchForecastForCity requests metric forecast and converts it to preferred units the way weather is
+func (o *OpenMeteo) fetchForecastForCity(ctx context.Context, city *models.City, days int) (*models.Forecast, error) {
+	preferences := integrations.PreferencesFrom(ctx)
+
+	var result HourlyDailyResponse
+	req, err := o.client.R().
+		SetContext(ctx).
+		SetResult(&result).
+		Get(o.cfg.OpenMeteo.ForecastURL + "?" + getForecastQuery(city, days))
+	if err != nil {
+		return nil, err
+	}
+	if !req.IsSuccess() {
+		return nil, errors.New("could not fetch forecast for city: " + city.Name)
+	}
+	if city.TimeZone == "" {
+		city.TimeZone = result.Timezone
+	}
+
+	forecast := &models.Forecast{
+		ID:       uuid.Must(uuid.NewV7()).String(),
+		Time:     time.Now(),
+		Units:    string(models.Metric),
+		Language: preferences.Language,
+		Provider: providerName,
+		CityID:   city.ID,
+		City:     *city,
+	}
+	daily := result.Daily
+	for i := range daily.Time {
+		// local midnight shifted by utc offset is the calendar date in UTC
+		date := time.Unix(daily.Time[i]+result.UTCOffsetSeconds, 0).UTC()
+		forecast.Days = append(forecast.Days, models.ForecastDay{
+			ID:                       uuid.Must(uuid.NewV7()).String(),
+			ForecastID:               forecast.ID,
+			Date:                     date,
+			MaxTemperature:           valueAt(daily.MaxTemperature, i),
+			MinTemperature:           valueAt(daily.MinTemperature, i),
+			PrecipitationProbability: int(valueAt(daily.PrecipitationProbability, i)),
+			Description:              weatherCodes[valueAt(daily.WeatherCode, i)],
+		})
+	}
+	hourly := result.Hourly
+	for i := range hourly.Time {
+		forecast.Hours = append(forecast.Hours, models.ForecastHour{
+			ID:                       uuid.Must(uuid.NewV7()).String(),
+			ForecastID:               forecast.ID,
+			Time:                     time.Unix(hourly.Time[i], 0),
+			Temperature:              valueAt(hourly.Temperature, i),
+			Humidity:                 int(valueAt(hourly.RelativeHumidity, i)),
+			PrecipitationProbability: int(valueAt(hourly.PrecipitationProbability, i)),
+			Description:              weatherCodes[valueAt(hourly.WeatherCode, i)],
+		})
+	}
+
+	return forecast.InUnits(preferences.Units), nil
+}
+
+func getForecastQuery(city *models.City, days int) string {
+	query := url.Values{}
+	cityCoordinates := city.GetStringCoordinates()
+	query.Set("latitude", cityCoordinates.Lat)
+	query.Set("longitude", cityCoordinates.Long)
+	query.Set("daily", dailyFields)
+	query.Set("hourly", hourlyFields)
+	query.Set("forecast_days", strconv.Itoa(days))
+	query.Set("forecast_hours", strconv.Itoa(integrations.ForecastHours))
+	query.Set("timeformat", "unixtime")
+	query.Set("timezone", "auto")
+
+	return query.Encode()
+}
+
+// valueAt returns value at index, Open-Meteo may return shorter series for the last days
+func valueAt[T any](values []T, i int) T {
+	var value T
+	if i < len(values) {
+		value = values[i]
+	}
+
+	return value
+}
@@ -0,0 +1,58 @@
+package openmeteo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"weather-subscriptions/internal/integrations"
+)
+
+const placeIDPrefix = "open-meteo:"
+
+// minPopulationShare is the smallest population of a result relative to the most relevant one for the
+// result to be a candidate, a search returns small places of the same name a user rarely means
+const minPopulationShare = 0.1
+
+func (o *OpenMeteo) fetchCityInfos(ctx context.Context, cityName string) ([]*CityInfo, error) {
+	query := url.Values{}
+	query.Set("name", cityName)
+	query.Set("count", strconv.Itoa(integrations.MaxCities))
+	query.Set("format", "json")
+
+	var result GeocodingResponse
+	req, err := o.client.R().
+		SetContext(ctx).
+		SetResult(&result).
+		Get(o.cfg.OpenMeteo.GeocodingURL + "?" + query.Encode())
+	if err != nil {
+		return nil, err
+	}
+	if !req.IsSuccess() {
+		return nil, errors.New("could not fetch city info: " + cityName)
+	}
+
+	if len(result.Results) == 0 {
+		return nil, fmt.Errorf("%w: no results found for city with name: '%s'", integrations.ErrNotFound, cityName)
+	}
+
+	minPopulation := float64(result.Results[0].Population) * minPopulationShare
+	cityInfos := make([]*CityInfo, 0, len(result.Results))
+	for i, place := range result.Results {
+		if i > 0 && float64(place.Population) < minPopulation {
+			continue
+		}
+		cityInfos = append(cityInfos, &CityInfo{
+			Name:      place.Name,
+			Region:    place.Admin1,
+			Country:   place.Country,
+			PlaceID:   placeIDPrefix + strconv.FormatInt(place.ID, 10),
+			Latitude:  place.Latitude,
+			Longitude: place.Longitude,
+			Timezone:  place.Timezone,
+		})
+	}
+
+	return cityInfos, nil
+}
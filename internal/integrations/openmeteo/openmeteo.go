@@ -0,0 +1,75 @@
+package openmeteo
+
+import (
+	"context"
+	"github.com/go-resty/resty/v2"
+	"github.com/google/uuid"
+	"github.com/gosimple/slug"
+	"go.uber.org/zap"
+	"weather-subscriptions/internal/config"
+	"weather-subscriptions/internal/db/models"
+	"weather-subscriptions/internal/integrations"
+)
+
+// OpenMeteo integration uses Open-Meteo forecast and geocoding APIs, which do not require an API key
+type OpenMeteo struct {
+	cfg    *config.Config
+	client *resty.Client
+}
+
+func New(cfg *config.Config) integrations.MapsIntegration {
+	return &OpenMeteo{
+		cfg:    cfg,
+		client: resty.New(),
+	}
+}
+
+func (o *OpenMeteo) GetWeather(ctx context.Context, city *models.City) (*models.Weather, error) {
+	weather, err := o.fetchWeatherForCity(ctx, city)
+	if err != nil {
+		zap.L().Error("failed to fetch weather", zap.Error(err))
+		return nil, err
+	}
+
+	return weather, nil
+}
+
+func (o *OpenMeteo) GetForecast(ctx context.Context, city *models.City, days int) (*models.Forecast, error) {
+	forecast, err := o.fetchForecastForCity(ctx, city, days)
+	if err != nil {
+		zap.L().Error("failed to fetch forecast", zap.Error(err))
+		return nil, err
+	}
+
+	return forecast, nil
+}
+
+// GetCityAt is not supported, Open-Meteo geocoding API searches by name only
+func (o *OpenMeteo) GetCityAt(context.Context, float64, float64) (*models.City, error) {
+	return nil, integrations.ErrUnsupported
+}
+
+func (o *OpenMeteo) GetCities(ctx context.Context, cityName string) ([]*models.City, error) {
+	cityInfos, err := o.fetchCityInfos(ctx, cityName)
+	if err != nil {
+		zap.L().Error("failed to fetch city info", zap.Error(err))
+		return nil, err
+	}
+
+	cities := make([]*models.City, 0, len(cityInfos))
+	for _, cityInfo := range cityInfos {
+		cities = append(cities, &models.City{
+			ID:            uuid.Must(uuid.NewV7()).String(),
+			Name:          slug.Make(cityInfo.Name),
+			FormattedName: integrations.FormatName(cityInfo.Name, cityInfo.Region, cityInfo.Country),
+			Region:        cityInfo.Region,
+			Country:       cityInfo.Country,
+			Latitude:      cityInfo.Latitude,
+			Longitude:     cityInfo.Longitude,
+			GooglePlaceID: cityInfo.PlaceID,
+			TimeZone:      cityInfo.Timezone,
+		})
+	}
+
+	return cities, nil
+}
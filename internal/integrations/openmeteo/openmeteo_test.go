@@ -0,0 +1,152 @@
+package openmeteo
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"weather-subscriptions/internal/config"
+	"weather-subscriptions/internal/db/models"
+	"weather-subscriptions/internal/integrations"
+)
+
+// newTestIntegration returns integration whose APIs are served from testdata, current weather and
+// forecast share the forecast endpoint and are told apart by the "current" parameter
+func newTestIntegration(t *testing.T) integrations.MapsIntegration {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/forecast", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Has("current") {
+			serveFixture(t, w, "current.json")
+			return
+		}
+		serveFixture(t, w, "forecast.json")
+	})
+	mux.HandleFunc("/v1/search", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("name") == "Kyiv" {
+			serveFixture(t, w, "geocoding.json")
+			return
+		}
+		serveFixture(t, w, "geocoding_empty.json")
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	cfg := &config.Config{}
+	cfg.OpenMeteo.ForecastURL = server.URL + "/v1/forecast"
+	cfg.OpenMeteo.GeocodingURL = server.URL + "/v1/search"
+
+	return New(cfg)
+}
+
+func serveFixture(t *testing.T, w http.ResponseWriter, name string) {
+	body, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Errorf("read fixture %s: %v", name, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(body)
+}
+
+func TestGetWeather(t *testing.T) {
+	city := &models.City{ID: "city-id", Name: "kyiv", Latitude: 50.45, Longitude: 30.52}
+
+	weather, err := newTestIntegration(t).GetWeather(context.Background(), city)
+	require.NoError(t, err)
+
+	assert.Equal(t, 21.4, weather.Temperature)
+	assert.Equal(t, 63, weather.Humidity)
+	assert.Equal(t, "Slight rain", weather.Description)
+	assert.Equal(t, "Slight rain", weather.Condition)
+	assert.Equal(t, 14.8, weather.WindSpeed)
+	assert.Equal(t, 31.3, weather.WindGust)
+	assert.Equal(t, 247, weather.WindDirection)
+	assert.Equal(t, "WEST_SOUTHWEST", weather.WindCardinal)
+	assert.Equal(t, 55, weather.PrecipitationProbability)
+	assert.Equal(t, 0.4, weather.Precipitation)
+	assert.Equal(t, 88, weather.CloudCover)
+	assert.Equal(t, 1012.6, weather.Pressure)
+	assert.InDelta(t, 24.14, weather.Visibility, 1e-9)
+	assert.Equal(t, 4, weather.UVIndex)
+	assert.Equal(t, string(models.Metric), weather.Units)
+	assert.Equal(t, models.DefaultLanguage, weather.Language)
+	assert.Equal(t, providerName, weather.Provider)
+	assert.Equal(t, "city-id", weather.CityID)
+	assert.Equal(t, "Europe/Kyiv", city.TimeZone, "unknown city timezone is taken from the response")
+}
+
+func TestGetWeatherInPreferredUnits(t *testing.T) {
+	city := &models.City{ID: "city-id", Name: "kyiv", Latitude: 50.45, Longitude: 30.52}
+	ctx := integrations.WithPreferences(context.Background(), integrations.Preferences{
+		Units:    models.Imperial,
+		Language: "uk",
+	})
+
+	weather, err := newTestIntegration(t).GetWeather(ctx, city)
+	require.NoError(t, err)
+
+	assert.Equal(t, string(models.Imperial), weather.Units)
+	assert.Equal(t, "uk", weather.Language)
+	assert.InDelta(t, 70.52, weather.Temperature, 0.01)
+}
+
+func TestGetForecast(t *testing.T) {
+	city := &models.City{ID: "city-id", Name: "kyiv", Latitude: 50.45, Longitude: 30.52}
+
+	forecast, err := newTestIntegration(t).GetForecast(context.Background(), city, 2)
+	require.NoError(t, err)
+
+	require.Len(t, forecast.Days, 2)
+	assert.Equal(t, time.Date(2025, time.June, 14, 0, 0, 0, 0, time.UTC), forecast.Days[0].Date)
+	assert.Equal(t, 24.1, forecast.Days[0].MaxTemperature)
+	assert.Equal(t, 13.2, forecast.Days[0].MinTemperature)
+	assert.Equal(t, 55, forecast.Days[0].PrecipitationProbability)
+	assert.Equal(t, "Slight rain", forecast.Days[0].Description)
+	assert.Equal(t, "Mainly clear", forecast.Days[1].Description)
+
+	require.Len(t, forecast.Hours, 2)
+	assert.True(t, forecast.Hours[0].Time.Equal(time.Unix(1749891600, 0)))
+	assert.Equal(t, 21.4, forecast.Hours[0].Temperature)
+	assert.Equal(t, 63, forecast.Hours[0].Humidity)
+	assert.Equal(t, "Overcast", forecast.Hours[1].Description)
+	assert.Equal(t, providerName, forecast.Provider)
+}
+
+func TestGetCities(t *testing.T) {
+	cities, err := newTestIntegration(t).GetCities(context.Background(), "Kyiv")
+	require.NoError(t, err)
+
+	// the second result is far less populous than the first one
+	require.Len(t, cities, 1)
+	city := cities[0]
+	assert.Equal(t, "kyiv", city.Name)
+	assert.Equal(t, "Kyiv, Kyiv City, Ukraine", city.FormattedName)
+	assert.Equal(t, "Kyiv City", city.Region)
+	assert.Equal(t, "Ukraine", city.Country)
+	assert.Equal(t, placeIDPrefix+"703448", city.GooglePlaceID)
+	assert.Equal(t, 50.45466, city.Latitude)
+	assert.Equal(t, 30.5238, city.Longitude)
+	assert.Equal(t, "Europe/Kyiv", city.TimeZone)
+	assert.NotEmpty(t, city.ID)
+}
+
+func TestGetCitiesNotFound(t *testing.T) {
+	cities, err := newTestIntegration(t).GetCities(context.Background(), "Atlantis")
+
+	assert.ErrorIs(t, err, integrations.ErrNotFound)
+	assert.Empty(t, cities)
+}
+
+func TestGetCityAtUnsupported(t *testing.T) {
+	_, err := newTestIntegration(t).GetCityAt(context.Background(), 50.45, 30.52)
+
+	assert.ErrorIs(t, err, integrations.ErrUnsupported)
+}
@@ -0,0 +1,98 @@
+package openmeteo
+
+type GeocodingResponse struct {
+	Results []struct {
+		ID         int64   `json:"id"`
+		Name       string  `json:"name"`
+		Latitude   float64 `json:"latitude"`
+		Longitude  float64 `json:"longitude"`
+		Timezone   string  `json:"timezone"`
+		Country    string  `json:"country"`
+		Admin1     string  `json:"admin1"`
+		Population int64   `json:"population"`
+	} `json:"results"`
+}
+
+type ForecastResponse struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Timezone  string  `json:"timezone"`
+	Current   struct {
+		Time                     string  `json:"time"`
+		Interval                 int     `json:"interval"`
+		Temperature              float64 `json:"temperature_2m"`
+		RelativeHumidity         int     `json:"relative_humidity_2m"`
+		WeatherCode              int     `json:"weather_code"`
+		WindSpeed                float64 `json:"wind_speed_10m"`
+		WindGusts                float64 `json:"wind_gusts_10m"`
+		WindDirection            float64 `json:"wind_direction_10m"`
+		Precipitation            float64 `json:"precipitation"`
+		PrecipitationProbability float64 `json:"precipitation_probability"`
+		CloudCover               float64 `json:"cloud_cover"`
+		PressureMSL              float64 `json:"pressure_msl"`
+		Visibility               float64 `json:"visibility"`
+		UVIndex                  float64 `json:"uv_index"`
+	} `json:"current"`
+}
+
+// HourlyDailyResponse is requested with unix time format, daily time is a local midnight
+type HourlyDailyResponse struct {
+	Timezone         string `json:"timezone"`
+	UTCOffsetSeconds int64  `json:"utc_offset_seconds"`
+	Daily            struct {
+		Time                     []int64   `json:"time"`
+		MaxTemperature           []float64 `json:"temperature_2m_max"`
+		MinTemperature           []float64 `json:"temperature_2m_min"`
+		PrecipitationProbability []float64 `json:"precipitation_probability_max"`
+		WeatherCode              []int     `json:"weather_code"`
+	} `json:"daily"`
+	Hourly struct {
+		Time                     []int64   `json:"time"`
+		Temperature              []float64 `json:"temperature_2m"`
+		RelativeHumidity         []float64 `json:"relative_humidity_2m"`
+		PrecipitationProbability []float64 `json:"precipitation_probability"`
+		WeatherCode              []int     `json:"weather_code"`
+	} `json:"hourly"`
+}
+
+type CityInfo struct {
+	Name      string  `json:"name"`
+	Region    string  `json:"region"`
+	Country   string  `json:"country"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	PlaceID   string  `json:"placeId"`
+	Timezone  string  `json:"timezone"`
+}
+
+// weatherCodes maps WMO weather interpretation codes to descriptions
+var weatherCodes = map[int]string{
+	0:  "Clear sky",
+	1:  "Mainly clear",
+	2:  "Partly cloudy",
+	3:  "Overcast",
+	45: "Fog",
+	48: "Depositing rime fog",
+	51: "Light drizzle",
+	53: "Moderate drizzle",
+	55: "Dense drizzle",
+	56: "Light freezing drizzle",
+	57: "Dense freezing drizzle",
+	61: "Slight rain",
+	63: "Moderate rain",
+	65: "Heavy rain",
+	66: "Light freezing rain",
+	67: "Heavy freezing rain",
+	71: "Slight snow fall",
+	73: "Moderate snow fall",
+	75: "Heavy snow fall",
+	77: "Snow grains",
+	80: "Slight rain showers",
+	81: "Moderate rain showers",
+	82: "Violent rain showers",
+	85: "Slight snow showers",
+	86: "Heavy snow showers",
+	95: "Thunderstorm",
+	96: "Thunderstorm with slight hail",
+	99: "Thunderstorm with heavy hail",
+}
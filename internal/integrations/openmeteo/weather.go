@@ -0,0 +1,92 @@
+package openmeteo
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"math"
+	"net/url"
+	"time"
+	"weather-subscriptions/internal/db/models"
+	"weather-subscriptions/internal/integrations"
+)
+
+const (
+	currentFields = "temperature_2m,relative_humidity_2m,weather_code,wind_speed_10m,wind_gusts_10m," +
+		"wind_direction_10m,precipitation,precipitation_probability,cloud_cover,pressure_msl,visibility,uv_index"
+	providerName = "open-meteo"
+	// visibility is reported in meters
+	metersInKilometer = 1000
+)
+
+// fetchWeatherForCity requests metric values and converts them to preferred units. Open-Meteo has no
+// localized descriptions, English ones are stored in preferred language so the city is not refetched
+func (o *OpenMeteo) fetchWeatherForCity(ctx context.Context, city *models.City) (*models.Weather, error) {
+	preferences := integrations.PreferencesFrom(ctx)
+
+	var result ForecastResponse
+	req, err := o.client.R().
+		SetContext(ctx).
+		SetResult(&result).
+		Get(o.cfg.OpenMeteo.ForecastURL + "?" + getQuery(city))
+	if err != nil {
+		return nil, err
+	}
+	if !req.IsSuccess() {
+		return nil, errors.New("could not fetch weather for city: " + city.Name)
+	}
+	if city.TimeZone == "" {
+		city.TimeZone = result.Timezone
+	}
+
+	current := result.Current
+	weather := &models.Weather{
+		ID:                       uuid.Must(uuid.NewV7()).String(),
+		Time:                     time.Now(),
+		Temperature:              current.Temperature,
+		Humidity:                 current.RelativeHumidity,
+		Description:              weatherCodes[current.WeatherCode],
+		Condition:                weatherCodes[current.WeatherCode],
+		WindSpeed:                current.WindSpeed,
+		WindGust:                 current.WindGusts,
+		WindDirection:            int(math.Round(current.WindDirection)),
+		WindCardinal:             cardinal(current.WindDirection),
+		PrecipitationProbability: int(current.PrecipitationProbability),
+		Precipitation:            current.Precipitation,
+		CloudCover:               int(current.CloudCover),
+		Pressure:                 current.PressureMSL,
+		Visibility:               current.Visibility / metersInKilometer,
+		UVIndex:                  int(math.Round(current.UVIndex)),
+		Units:                    string(models.Metric),
+		Language:                 preferences.Language,
+		Provider:                 providerName,
+		CityID:                   city.ID,
+		City:                     *city,
+	}
+
+	return weather.InUnits(preferences.Units), nil
+}
+
+var cardinals = []string{
+	"NORTH", "NORTH_NORTHEAST", "NORTHEAST", "EAST_NORTHEAST",
+	"EAST", "EAST_SOUTHEAST", "SOUTHEAST", "SOUTH_SOUTHEAST",
+	"SOUTH", "SOUTH_SOUTHWEST", "SOUTHWEST", "WEST_SOUTHWEST",
+	"WEST", "WEST_NORTHWEST", "NORTHWEST", "NORTH_NORTHWEST",
+}
+
+// cardinal converts wind direction degrees to 16-point cardinal direction named the way Google does
+func cardinal(degrees float64) string {
+	index := int(math.Round(math.Mod(degrees, 360)/22.5)) % len(cardinals)
+	return cardinals[index]
+}
+
+func getQuery(city *models.City) string {
+	query := url.Values{}
+	cityCoordinates := city.GetStringCoordinates()
+	query.Set("latitude", cityCoordinates.Lat)
+	query.Set("longitude", cityCoordinates.Long)
+	query.Set("current", currentFields)
+	query.Set("timezone", "auto")
+
+	return query.Encode()
+}
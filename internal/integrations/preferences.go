@@ -0,0 +1,33 @@
+package integrations
+
+import (
+	"context"
+	"weather-subscriptions/internal/db/models"
+)
+
+// Preferences are units and language weather descriptions are requested in
+type Preferences struct {
+	Units    models.Units
+	Language string
+}
+
+type preferencesKey struct{}
+
+// WithPreferences returns context which requests weather in the preferences
+func WithPreferences(ctx context.Context, preferences Preferences) context.Context {
+	return context.WithValue(ctx, preferencesKey{}, preferences)
+}
+
+// PreferencesFrom returns preferences of the context, metric units in default language are used
+// when the context has none
+func PreferencesFrom(ctx context.Context) Preferences {
+	preferences, _ := ctx.Value(preferencesKey{}).(Preferences)
+	if preferences.Units == "" {
+		preferences.Units = models.Metric
+	}
+	if preferences.Language == "" {
+		preferences.Language = models.DefaultLanguage
+	}
+
+	return preferences
+}
@@ -0,0 +1,47 @@
+package providers
+
+import (
+	"fmt"
+	"strings"
+	"weather-subscriptions/internal/config"
+	"weather-subscriptions/internal/integrations"
+	"weather-subscriptions/internal/integrations/failover"
+	"weather-subscriptions/internal/integrations/google"
+	"weather-subscriptions/internal/integrations/openmeteo"
+)
+
+const (
+	Google    = "google"
+	OpenMeteo = "open-meteo"
+)
+
+// New creates weather integration selected by WEATHER_PROVIDER config key.
+// Comma-separated list of providers creates failover chain which tries them in the given order
+func New(cfg *config.Config) (integrations.MapsIntegration, error) {
+	names := strings.Split(cfg.WeatherProvider, ",")
+	chain := make([]failover.Provider, 0, len(names))
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		integration, err := newProvider(cfg, name)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, failover.Provider{Name: name, Integration: integration})
+	}
+	if len(chain) == 1 {
+		return chain[0].Integration, nil
+	}
+
+	return failover.New(cfg.Failover.Threshold, cfg.Failover.Cooldown, chain...), nil
+}
+
+func newProvider(cfg *config.Config, name string) (integrations.MapsIntegration, error) {
+	switch name {
+	case Google:
+		return google.New(cfg), nil
+	case OpenMeteo:
+		return openmeteo.New(cfg), nil
+	default:
+		return nil, fmt.Errorf("unknown weather provider: '%s'", name)
+	}
+}
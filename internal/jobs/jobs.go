@@ -0,0 +1,110 @@
+package jobs
+
+import (
+	"fmt"
+	"go.uber.org/zap"
+	"os"
+	"time"
+	"weather-subscriptions/internal/db/models"
+	"weather-subscriptions/internal/mail"
+	"weather-subscriptions/internal/state"
+)
+
+// Locker makes scheduled jobs run once per slot across all replicas sharing the database.
+// Slot is the job start time truncated to the job interval, the first replica to record it runs the job.
+// The run is leased while the job is in progress, so a run of a replica which stopped is taken over
+// by the next attempt of the slot after the lease expires
+type Locker struct {
+	state  state.Stateful
+	runner string
+	lease  time.Duration
+}
+
+func NewLocker(state state.Stateful, lease time.Duration) *Locker {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+
+	return &Locker{
+		state:  state,
+		runner: fmt.Sprintf("%s:%d", hostname, os.Getpid()),
+		lease:  lease,
+	}
+}
+
+// Once wraps job to skip slots claimed by another replica and to record the run result, job should be
+// scheduled more often than its interval, so a stale claim of its slot is taken over. Job gets the slot
+// it runs for, a slot which is taken over is run again, so job has to skip work the slot already did
+func (l *Locker) Once(name string, interval time.Duration, job func(slot time.Time) (mail.BatchStats, error)) func() {
+	return func() {
+		now := time.Now()
+		run := &models.JobRun{
+			Job:         name,
+			Slot:        now.Truncate(interval),
+			Runner:      l.runner,
+			StartedAt:   now,
+			LeasedUntil: now.Add(l.lease),
+		}
+		claimed, err := l.state.ClaimJobRun(run)
+		if err != nil {
+			zap.L().Error("failed to claim job run", zap.String("job", name), zap.Error(err))
+			return
+		}
+		if !claimed {
+			zap.L().Debug("job slot is taken by another runner", zap.String("job", name), zap.Time("slot", run.Slot))
+			return
+		}
+
+		stop := l.renew(run)
+		stats, err := job(run.Slot)
+		stop()
+		finishedAt := time.Now()
+		run.FinishedAt = &finishedAt
+		run.Sent = stats.Sent
+		run.Failed = stats.Failed
+		run.Skipped = stats.Skipped
+		if err != nil {
+			run.Error = err.Error()
+		}
+		finished, err := l.state.FinishJobRun(run)
+		if err != nil {
+			zap.L().Error("failed to save job run", zap.String("job", name), zap.Error(err))
+			return
+		}
+		if !finished {
+			zap.L().Warn("job run was taken over by another runner", zap.String("job", name), zap.Time("slot", run.Slot))
+		}
+	}
+}
+
+// renew extends lease of the run every half of the lease until stop is called
+func (l *Locker) renew(run *models.JobRun) (stop func()) {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(l.lease / 2)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case now := <-ticker.C:
+				renewed := *run
+				renewed.LeasedUntil = now.Add(l.lease)
+				renewedRun, err := l.state.RenewJobRun(&renewed)
+				if err != nil {
+					zap.L().Warn("failed to renew job run", zap.String("job", run.Job), zap.Error(err))
+				} else if !renewedRun {
+					zap.L().Warn("job run lease was taken over by another runner", zap.String("job", run.Job))
+				}
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
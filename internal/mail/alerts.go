@@ -0,0 +1,92 @@
+package mail
+
+import (
+	"go.uber.org/zap"
+	"time"
+	"weather-subscriptions/internal/db/models"
+	mail "weather-subscriptions/internal/mail/mailer_service"
+	"weather-subscriptions/internal/mail/outbox"
+	"weather-subscriptions/internal/state"
+	"weather-subscriptions/internal/templates"
+)
+
+// SendAlerts checks fresh weather against rules of "alert" subscriptions and notifies users about
+// conditions which became true. Rule stays triggered until its condition turns false,
+// so a lasting condition is reported only once
+func (m *Manager) SendAlerts(slot time.Time) (BatchStats, error) {
+	subscriptions, err := m.state.GetSubscriptions(models.ALERT)
+	if err != nil {
+		zap.L().Error("failed to get subscriptions", zap.Error(err))
+		return BatchStats{}, err
+	}
+
+	stats := m.processBatch(subscriptions, func(subscription *models.Subscription, weather *models.Weather) (bool, error) {
+		unsubToken, manageToken, err := m.subscriptionTokens(subscription)
+		if err != nil {
+			return false, err
+		}
+
+		// rule state and alert email are committed together, so an alert is neither lost nor repeated
+		sent := false
+		err = m.state.Transaction(func(tx state.Stateful) error {
+			triggered, err := updateAlertRules(tx, subscription, weather)
+			if err != nil || len(triggered) == 0 {
+				return err
+			}
+
+			locale := subscriberLocale(subscription)
+			body, err := templates.GetAlertEmailBody(
+				locale,
+				weather,
+				triggered,
+				m.cfg.FrontendURL,
+				unsubToken.Token,
+				manageToken.Token,
+			)
+			if err != nil {
+				return err
+			}
+
+			sent, err = outbox.EnqueueOnce(tx, slotKey(models.ALERT, slot, subscription), mail.MailMessage{
+				To:      []string{subscription.User.Email},
+				Subject: locale.Sprintf("subject.alert", subscription.City.DisplayName()),
+				Body:    body,
+				Headers: m.unsubscribeHeaders(unsubToken.Token),
+			})
+			return err
+		})
+
+		return sent && err == nil, err
+	})
+	logStats(models.ALERT, stats)
+
+	return stats, nil
+}
+
+// updateAlertRules stores rule state for the weather and returns rules which conditions have just become true.
+// Thresholds are compared in subscriber units
+func updateAlertRules(tx state.Stateful, subscription *models.Subscription, weather *models.Weather) ([]models.AlertRule, error) {
+	weather = weather.InUnits(subscriberLocale(subscription).Units)
+
+	var triggered []models.AlertRule
+	for i := range subscription.AlertRules {
+		rule := &subscription.AlertRules[i]
+		matches := rule.Matches(weather)
+		if matches == rule.Triggered {
+			continue
+		}
+
+		rule.Triggered = matches
+		if matches {
+			now := time.Now()
+			rule.TriggeredAt = &now
+			triggered = append(triggered, *rule)
+		}
+		err := tx.SaveAlertRule(rule)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return triggered, nil
+}
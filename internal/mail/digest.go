@@ -0,0 +1,63 @@
+package mail
+
+import (
+	"context"
+	"time"
+	"weather-subscriptions/internal/db/models"
+	"weather-subscriptions/internal/integrations"
+	mail "weather-subscriptions/internal/mail/mailer_service"
+	"weather-subscriptions/internal/mail/outbox"
+	"weather-subscriptions/internal/templates"
+)
+
+const forecastLifetime = time.Hour
+
+// sendDigest enqueues daily digest emails with the forecast of the subscription city for the day ahead
+func (m *Manager) sendDigest(subscriptions []*models.Subscription, slot time.Time) BatchStats {
+	return processCities(subscriptions, m.getForecastForCities, func(subscription *models.Subscription, forecast *models.Forecast) (bool, error) {
+		unsubToken, manageToken, err := m.subscriptionTokens(subscription)
+		if err != nil {
+			return false, err
+		}
+		location, err := time.LoadLocation(subscription.Timezone)
+		if err != nil {
+			location = time.UTC
+		}
+
+		locale := subscriberLocale(subscription)
+		body, err := templates.GetDailyDigestBody(
+			locale,
+			forecast,
+			subscription.City.DisplayName(),
+			location,
+			m.cfg.FrontendURL,
+			unsubToken.Token,
+			manageToken.Token,
+		)
+		if err != nil {
+			return false, err
+		}
+
+		return outbox.EnqueueOnce(m.state, slotKey(models.DAILY, slot, subscription), mail.MailMessage{
+			To:      []string{subscription.User.Email},
+			Subject: locale.Sprintf("subject.daily", subscription.City.DisplayName()),
+			Body:    body,
+			Headers: m.unsubscribeHeaders(unsubToken.Token),
+		})
+	})
+}
+
+// getForecastForCities returns recent forecasts for the cities, the longest supported forecast is fetched
+// to be reused by forecast API. Cities which forecast could not be fetched are omitted
+func (m *Manager) getForecastForCities(cities map[cityKey][]*models.Subscription) map[cityKey]*models.Forecast {
+	return loadCities(m, cities, cityLoader[*models.Forecast]{
+		stored: m.state.GetForecast,
+		fresh: func(forecast *models.Forecast) bool {
+			return forecast.Time.After(time.Now().Add(-forecastLifetime))
+		},
+		fetch: func(ctx context.Context, city *models.City) (*models.Forecast, error) {
+			return m.weatherIntegration.GetForecast(ctx, city, integrations.MaxForecastDays)
+		},
+		save: m.state.SaveForecast,
+	})
+}
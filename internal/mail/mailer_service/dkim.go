@@ -0,0 +1,159 @@
+package mailer_service
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// signedHeaders are covered by the signature when message has them, From is always signed
+var signedHeaders = []string{
+	"From", "Reply-To", "To", "Subject", "Date", "Message-ID", "MIME-Version", "Content-Type",
+	"List-Unsubscribe", "List-Unsubscribe-Post",
+}
+
+// DKIMSigner signs messages with rsa-sha256 and relaxed/relaxed canonicalization (RFC 6376).
+// Public key is published in TXT record <selector>._domainkey.<domain>
+type DKIMSigner struct {
+	Domain   string
+	Selector string
+	key      *rsa.PrivateKey
+}
+
+// NewDKIMSigner reads PEM encoded PKCS #1 or PKCS #8 RSA private key from keyPath
+func NewDKIMSigner(domain, selector, keyPath string) (*DKIMSigner, error) {
+	content, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, err
+	}
+	key, err := parsePrivateKey(content)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DKIM private key %s: %w", keyPath, err)
+	}
+
+	return &DKIMSigner{Domain: domain, Selector: selector, key: key}, nil
+}
+
+func parsePrivateKey(content []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(content)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("only RSA keys are supported")
+	}
+
+	return key, nil
+}
+
+// Sign returns raw CRLF separated message with DKIM-Signature header prepended
+func (s *DKIMSigner) Sign(message []byte) ([]byte, error) {
+	header, body, found := bytes.Cut(message, []byte("\r\n\r\n"))
+	if !found {
+		return nil, errors.New("message has no body separator")
+	}
+	fields := headerFields(string(header) + "\r\n")
+
+	var names []string
+	var canonical strings.Builder
+	for _, name := range signedHeaders {
+		if field, ok := fields[strings.ToLower(name)]; ok {
+			names = append(names, name)
+			canonical.WriteString(relaxedHeader(field) + "\r\n")
+		}
+	}
+	if len(names) == 0 || names[0] != "From" {
+		return nil, errors.New("message has no From header")
+	}
+
+	bodyHash := sha256.Sum256([]byte(relaxedBody(string(body))))
+	signature := fmt.Sprintf(
+		"DKIM-Signature: v=1; a=rsa-sha256; c=relaxed/relaxed; d=%s; s=%s; t=%d; h=%s; bh=%s; b=",
+		s.Domain,
+		s.Selector,
+		time.Now().Unix(),
+		strings.Join(names, ":"),
+		base64.StdEncoding.EncodeToString(bodyHash[:]),
+	)
+	// signature header is hashed with empty b= and without trailing CRLF
+	canonical.WriteString(relaxedHeader(signature))
+	hash := sha256.Sum256([]byte(canonical.String()))
+	signed, err := rsa.SignPKCS1v15(rand.Reader, s.key, crypto.SHA256, hash[:])
+	if err != nil {
+		return nil, err
+	}
+
+	signature += base64.StdEncoding.EncodeToString(signed) + "\r\n"
+	return append([]byte(signature), message...), nil
+}
+
+// headerFields returns raw folded header fields by lowercase name, last instance of a field wins
+func headerFields(header string) map[string]string {
+	fields := make(map[string]string)
+	var field string
+	flush := func() {
+		if name, _, ok := strings.Cut(field, ":"); ok {
+			fields[strings.ToLower(strings.TrimSpace(name))] = field
+		}
+	}
+	for _, line := range strings.SplitAfter(header, "\r\n") {
+		if line == "" {
+			continue
+		}
+		if line[0] == ' ' || line[0] == '\t' {
+			field += line
+			continue
+		}
+		flush()
+		field = line
+	}
+	flush()
+
+	return fields
+}
+
+// relaxedHeader lowercases field name, unfolds the value and collapses its whitespace
+func relaxedHeader(field string) string {
+	name, value, _ := strings.Cut(field, ":")
+	value = strings.NewReplacer("\r\n", "").Replace(value)
+
+	return strings.ToLower(strings.TrimSpace(name)) + ":" + strings.Join(strings.Fields(value), " ")
+}
+
+// relaxedBody collapses whitespace of lines, removes trailing whitespace and trailing empty lines
+func relaxedBody(body string) string {
+	lines := strings.Split(body, "\r\n")
+	for i, line := range lines {
+		line = strings.TrimRight(line, " \t")
+		lines[i] = strings.Join(strings.FieldsFunc(line, func(r rune) bool { return r == ' ' || r == '\t' }), " ")
+		if strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t") {
+			lines[i] = " " + lines[i]
+		}
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+
+	return strings.Join(lines, "\r\n") + "\r\n"
+}
@@ -0,0 +1,192 @@
+package mailer_service
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"weather-subscriptions/internal/config"
+)
+
+// Signatures are verified by canonicalization written independently of the signer following RFC 6376
+
+var (
+	whitespace   = regexp.MustCompile(`[ \t]+`)
+	signatureTag = regexp.MustCompile(`(^|;)(\s*b=)[^;]*`)
+)
+
+func newTestSigner(t *testing.T) (*DKIMSigner, *rsa.PublicKey) {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	keyPath := filepath.Join(t.TempDir(), "dkim.pem")
+	require.NoError(t, os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600))
+
+	signer, err := NewDKIMSigner("example.com", "mail", keyPath)
+	require.NoError(t, err)
+
+	return signer, &key.PublicKey
+}
+
+// verify checks body hash and signature of the first DKIM-Signature header of the message
+func verify(t *testing.T, key *rsa.PublicKey, message []byte) (map[string]string, error) {
+	t.Helper()
+
+	header, body, found := strings.Cut(string(message), "\r\n\r\n")
+	require.True(t, found, "message has no body separator")
+	var fields []string
+	for _, line := range strings.Split(header, "\r\n") {
+		if strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t") {
+			fields[len(fields)-1] += "\r\n" + line
+			continue
+		}
+		fields = append(fields, line)
+	}
+	require.True(t, strings.HasPrefix(fields[0], "DKIM-Signature:"), "signature is the first header")
+	signature := fields[0]
+
+	_, value, _ := strings.Cut(signature, ":")
+	tags := make(map[string]string)
+	for _, tag := range strings.Split(value, ";") {
+		name, tagValue, _ := strings.Cut(tag, "=")
+		tags[strings.TrimSpace(name)] = strings.Join(strings.Fields(tagValue), "")
+	}
+
+	bodyHash := sha256.Sum256([]byte(canonicalBody(body)))
+	if base64.StdEncoding.EncodeToString(bodyHash[:]) != tags["bh"] {
+		return tags, errors.New("body hash does not match")
+	}
+
+	var signed strings.Builder
+	for _, name := range strings.Split(tags["h"], ":") {
+		// the last instance of a header is signed first
+		for i := len(fields) - 1; i > 0; i-- {
+			fieldName, _, _ := strings.Cut(fields[i], ":")
+			if strings.EqualFold(strings.TrimSpace(fieldName), name) {
+				signed.WriteString(canonicalHeader(fields[i]) + "\r\n")
+				break
+			}
+		}
+	}
+	signed.WriteString(canonicalHeader(signatureTag.ReplaceAllString(signature, "$1$2")))
+
+	decoded, err := base64.StdEncoding.DecodeString(tags["b"])
+	require.NoError(t, err)
+	hash := sha256.Sum256([]byte(signed.String()))
+
+	return tags, rsa.VerifyPKCS1v15(key, crypto.SHA256, hash[:], decoded)
+}
+
+func canonicalHeader(field string) string {
+	name, value, _ := strings.Cut(field, ":")
+	value = strings.ReplaceAll(value, "\r\n", "")
+	value = strings.TrimSpace(whitespace.ReplaceAllString(value, " "))
+
+	return strings.ToLower(strings.TrimSpace(name)) + ":" + value
+}
+
+func canonicalBody(body string) string {
+	lines := strings.Split(body, "\r\n")
+	for i := range lines {
+		lines[i] = strings.TrimRight(whitespace.ReplaceAllString(lines[i], " "), " ")
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+
+	return strings.Join(lines, "\r\n") + "\r\n"
+}
+
+func TestSignGomailMessage(t *testing.T) {
+	signer, key := newTestSigner(t)
+	cfg := &config.Config{}
+	cfg.Mailer.From = "Weather <weather@example.com>"
+	cfg.Mailer.ReplyTo = "support@example.com"
+	mailer := &Mailer{cfg: cfg, signer: signer}
+
+	raw, err := mailer.build(MailMessage{
+		To:      []string{"user@example.org"},
+		Subject: "Погода в Києві: a subject long enough to be folded by the encoder of the message headers",
+		Body:    "<p>Temperature:   21.4 °C</p>\r\n\r\n\r\n",
+		Text:    "Temperature:\t21.4 °C  \r\n",
+		Headers: map[string]string{
+			"List-Unsubscribe":      "<https://weather.example.com/unsubscribe/code>",
+			"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+		},
+	})
+	require.NoError(t, err)
+	var message bytes.Buffer
+	_, err = raw.WriteTo(&message)
+	require.NoError(t, err)
+
+	tags, err := verify(t, key, message.Bytes())
+	require.NoError(t, err)
+
+	assert.Equal(t, "rsa-sha256", tags["a"])
+	assert.Equal(t, "relaxed/relaxed", tags["c"])
+	assert.Equal(t, "example.com", tags["d"])
+	assert.Equal(t, "mail", tags["s"])
+	assert.True(t, strings.HasPrefix(tags["h"], "From:"), "From is signed")
+	for _, name := range []string{"Reply-To", "To", "Subject", "Content-Type", "List-Unsubscribe"} {
+		assert.Contains(t, strings.Split(tags["h"], ":"), name)
+	}
+}
+
+func TestSignCanonicalizesWhitespace(t *testing.T) {
+	signer, key := newTestSigner(t)
+	message := "From: weather@example.com\r\n" +
+		"Subject:  folded\r\n\t  subject  \r\n" +
+		"To: user@example.org\r\n" +
+		"\r\n" +
+		"  leading and trailing whitespace \t\r\n" +
+		"tabs\t\tinside\r\n" +
+		"\r\n" +
+		"\r\n"
+
+	signed, err := signer.Sign([]byte(message))
+	require.NoError(t, err)
+
+	_, err = verify(t, key, signed)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasSuffix(string(signed), message), "message is left unchanged")
+}
+
+func TestSignDetectsModifiedMessage(t *testing.T) {
+	signer, key := newTestSigner(t)
+	message := "From: weather@example.com\r\nSubject: Weather\r\n\r\nTemperature: 21.4\r\n"
+	signed, err := signer.Sign([]byte(message))
+	require.NoError(t, err)
+
+	_, err = verify(t, key, []byte(strings.Replace(string(signed), "21.4", "31.4", 1)))
+	assert.Error(t, err, "modified body")
+	_, err = verify(t, key, []byte(strings.Replace(string(signed), "Subject: Weather", "Subject: Spam", 1)))
+	assert.Error(t, err, "modified header")
+	_, err = verify(t, key, []byte(strings.Replace(string(signed), "Temperature: 21.4", "Temperature:   21.4  ", 1)))
+	assert.NoError(t, err, "whitespace changes survive relaxed canonicalization")
+}
+
+func TestSignRequiresFrom(t *testing.T) {
+	signer, _ := newTestSigner(t)
+
+	_, err := signer.Sign([]byte("To: user@example.org\r\n\r\nbody\r\n"))
+
+	assert.Error(t, err)
+}
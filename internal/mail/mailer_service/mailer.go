@@ -1,7 +1,9 @@
 package mailer_service
 
 import (
+	"bytes"
 	"gopkg.in/gomail.v2"
+	"io"
 	"weather-subscriptions/internal/config"
 )
 
@@ -10,24 +12,80 @@ type MailerService interface {
 }
 
 type Mailer struct {
-	cfg *config.Config
+	cfg    *config.Config
+	signer *DKIMSigner
 }
 
-func New(cfg *config.Config) MailerService {
-	return &Mailer{cfg: cfg}
+// New returns SMTP mailer, messages are DKIM signed when DKIM domain is configured
+func New(cfg *config.Config) (MailerService, error) {
+	mailer := &Mailer{cfg: cfg}
+	if cfg.Mailer.DKIM.Domain != "" {
+		signer, err := NewDKIMSigner(cfg.Mailer.DKIM.Domain, cfg.Mailer.DKIM.Selector, cfg.Mailer.DKIM.PrivateKeyPath)
+		if err != nil {
+			return nil, err
+		}
+		mailer.signer = signer
+	}
+
+	return mailer, nil
 }
 
 func (m *Mailer) Send(message MailMessage) error {
 	client := gomail.NewDialer(m.cfg.Mailer.SMTP, m.cfg.Mailer.Port, m.cfg.Mailer.From, m.cfg.Mailer.Password)
 
+	raw, err := m.build(message)
+	if err != nil {
+		return err
+	}
+	sender, err := client.Dial()
+	if err != nil {
+		return err
+	}
+	defer sender.Close()
+
+	return sender.Send(m.envelopeSender(), message.To, raw)
+}
+
+// build returns raw message, signed when signer is set
+func (m *Mailer) build(message MailMessage) (io.WriterTo, error) {
 	msg := gomail.NewMessage()
 	msg.SetHeader("From", m.cfg.Mailer.From)
-	msg.SetHeader("To", message.To[0])
+	msg.SetHeader("To", message.To...)
 	msg.SetHeader("Subject", message.Subject)
-	msg.SetBody("text/html", message.Body)
-	if err := client.DialAndSend(msg); err != nil {
-		return err
+	if m.cfg.Mailer.ReplyTo != "" {
+		msg.SetHeader("Reply-To", m.cfg.Mailer.ReplyTo)
+	}
+	for name, value := range message.Headers {
+		msg.SetHeader(name, value)
+	}
+	if message.Text != "" {
+		msg.SetBody("text/plain", message.Text)
+		msg.AddAlternative("text/html", message.Body)
+	} else {
+		msg.SetBody("text/html", message.Body)
+	}
+	if m.signer == nil {
+		return msg, nil
+	}
+
+	var raw bytes.Buffer
+	_, err := msg.WriteTo(&raw)
+	if err != nil {
+		return nil, err
+	}
+	signed, err := m.signer.Sign(raw.Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	return bytes.NewBuffer(signed), nil
+}
+
+// envelopeSender is MAIL FROM address bounces are returned to, receivers record it as Return-Path
+func (m *Mailer) envelopeSender() string {
+	if m.cfg.Mailer.ReturnPath != "" {
+		return m.cfg.Mailer.ReturnPath
 	}
 
-	return nil
+	return m.cfg.Mailer.From
 }
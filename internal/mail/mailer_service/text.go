@@ -0,0 +1,95 @@
+package mailer_service
+
+import (
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"strings"
+)
+
+// hidden elements are not rendered by mail clients, their text is dropped
+var hidden = map[atom.Atom]bool{
+	atom.Head:   true,
+	atom.Title:  true,
+	atom.Style:  true,
+	atom.Script: true,
+}
+
+// blocks are put on separate lines
+var blocks = map[atom.Atom]bool{
+	atom.P: true, atom.Div: true, atom.Br: true, atom.Tr: true, atom.Table: true, atom.Ul: true,
+	atom.H1: true, atom.H2: true, atom.H3: true, atom.H4: true, atom.Form: true,
+}
+
+// PlainText converts html body to its plain text alternative. Markup is dropped, blocks are put
+// on separate lines, list items are prefixed with a dash and links are followed by their address
+func PlainText(body string) string {
+	tokenizer := html.NewTokenizer(strings.NewReader(body))
+	var text strings.Builder
+	hiddenDepth := 0
+	href := ""
+	for {
+		token := tokenizer.Next()
+		switch token {
+		case html.ErrorToken:
+			return tidy(text.String())
+		case html.TextToken:
+			if hiddenDepth == 0 {
+				text.WriteString(strings.Join(strings.Fields(string(tokenizer.Text())), " "))
+				text.WriteString(" ")
+			}
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttributes := tokenizer.TagName()
+			tag := atom.Lookup(name)
+			switch {
+			case hidden[tag] && token == html.StartTagToken:
+				hiddenDepth++
+			case tag == atom.Li:
+				text.WriteString("\n- ")
+			case tag == atom.A && hasAttributes:
+				href = attribute(tokenizer, "href")
+			case blocks[tag]:
+				text.WriteString("\n")
+			}
+		case html.EndTagToken:
+			name, _ := tokenizer.TagName()
+			tag := atom.Lookup(name)
+			switch {
+			case hidden[tag]:
+				hiddenDepth = max(hiddenDepth-1, 0)
+			case tag == atom.A && href != "":
+				text.WriteString("(" + href + ") ")
+				href = ""
+			case blocks[tag]:
+				text.WriteString("\n")
+			}
+		}
+	}
+}
+
+func attribute(tokenizer *html.Tokenizer, name string) string {
+	for {
+		key, value, more := tokenizer.TagAttr()
+		if string(key) == name {
+			return string(value)
+		}
+		if !more {
+			return ""
+		}
+	}
+}
+
+// tidy trims lines and leaves at most one empty line between paragraphs
+func tidy(text string) string {
+	var lines []string
+	empty := true
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" && empty {
+			continue
+		}
+		empty = line == ""
+		lines = append(lines, line)
+	}
+
+	return strings.TrimSpace(strings.Join(lines, "\n")) + "\n"
+}
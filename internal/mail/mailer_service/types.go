@@ -11,8 +11,12 @@ type SMTPConfig struct {
 
 // MailMessage represents an email message.
 // It contains all necessary fields for constructing and sending an email.
+// Text is plain-text alternative of the html Body, it is omitted when empty.
+// Headers are added to the message as is
 type MailMessage struct {
 	To      []string
 	Subject string
 	Body    string
+	Text    string
+	Headers map[string]string
 }
@@ -5,125 +5,312 @@ import (
 	"errors"
 	"fmt"
 	"go.uber.org/zap"
-	"gorm.io/gorm"
-	"strings"
 	"sync"
 	"time"
 	"weather-subscriptions/internal/config"
 	"weather-subscriptions/internal/db/models"
 	"weather-subscriptions/internal/integrations"
-	"weather-subscriptions/internal/integrations/google"
 	mail "weather-subscriptions/internal/mail/mailer_service"
+	"weather-subscriptions/internal/mail/outbox"
 	"weather-subscriptions/internal/state"
+	"weather-subscriptions/internal/subscriptions"
 	"weather-subscriptions/internal/templates"
+	"weather-subscriptions/internal/workers"
 )
 
 const weatherLifetime = 5 * time.Minute
 
-// MailManager interface to send emails to subscribed users
+// MailManager interface to send emails to subscribed users. Emails are sent for the job slot and are
+// queued once per subscription and slot, so a slot run again after a takeover skips queued emails
 type MailManager interface {
-	SendHourly() error
-	SendDaily() error
+	SendHourly(slot time.Time) (BatchStats, error)
+	SendDaily(slot time.Time) (BatchStats, error)
+	SendAlerts(slot time.Time) (BatchStats, error)
+}
+
+// BatchStats counts subscriptions processed by a single batch
+type BatchStats struct {
+	// Sent is a number of emails enqueued for delivery
+	Sent int
+	// Failed is a number of subscriptions which could not get weather or email
+	Failed int
+	// Skipped is a number of subscriptions which had nothing to send
+	Skipped int
+}
+
+func (s *BatchStats) add(other BatchStats) {
+	s.Sent += other.Sent
+	s.Failed += other.Failed
+	s.Skipped += other.Skipped
 }
 
 type Manager struct {
 	cfg                *config.Config
 	state              state.Stateful
-	mailer             mail.MailerService
 	weatherIntegration integrations.MapsIntegration
 	ctx                context.Context
 }
 
 // SendHourly sends email with current weather information to users with "hourly" subscription
-func (m *Manager) SendHourly() error {
+func (m *Manager) SendHourly(slot time.Time) (BatchStats, error) {
 	subscriptions, err := m.state.GetSubscriptions(models.HOURLY)
 	if err != nil {
 		zap.L().Error("failed to get subscriptions", zap.Error(err))
-		return err
+		return BatchStats{}, err
 	}
 
-	err = m.sendMail(subscriptions, models.HOURLY)
+	stats := m.sendMail(subscriptions, models.HOURLY, slot)
+	logStats(models.HOURLY, stats)
+
+	return stats, nil
+}
+
+// SendDaily sends digest with the day forecast to users with "daily" subscription
+// whose local delivery hour has come in their timezone at the slot
+func (m *Manager) SendDaily(slot time.Time) (BatchStats, error) {
+	timezones, err := m.state.GetSubscriptionTimezones(models.DAILY)
 	if err != nil {
-		zap.L().Error("failed to send mail", zap.Error(err))
-		return err
+		zap.L().Error("failed to get subscription timezones", zap.Error(err))
+		return BatchStats{}, err
+	}
+
+	var stats BatchStats
+	for _, timezone := range timezones {
+		location, err := time.LoadLocation(timezone)
+		if err != nil {
+			zap.L().Error("invalid subscription timezone", zap.String("timezone", timezone), zap.Error(err))
+			continue
+		}
+		hours := dueHours(slot, location)
+		if len(hours) == 0 {
+			continue
+		}
+
+		subscriptions, err := m.state.GetSubscriptionsAt(models.DAILY, timezone, hours)
+		if err != nil {
+			zap.L().Error("failed to get subscriptions", zap.Error(err))
+			return stats, err
+		}
+		stats.add(m.sendDigest(subscriptions, slot))
 	}
+	logStats(models.DAILY, stats)
 
-	return nil
+	return stats, nil
 }
 
-// SendDaily sends email with current weather information to users with "daily" subscription
-func (m *Manager) SendDaily() error {
-	subscriptions, err := m.state.GetSubscriptions(models.DAILY)
-	if err != nil {
-		return err
+// sendMail enqueues weather emails to the outbox, delivery is done by outbox dispatcher
+func (m *Manager) sendMail(subscriptions []*models.Subscription, subType models.SubscriptionType, slot time.Time) BatchStats {
+	return m.processBatch(subscriptions, func(subscription *models.Subscription, weather *models.Weather) (bool, error) {
+		unsubToken, manageToken, err := m.subscriptionTokens(subscription)
+		if err != nil {
+			return false, err
+		}
+
+		locale := subscriberLocale(subscription)
+		body, err := templates.GetWeatherEmailBody(locale, weather, m.cfg.FrontendURL, unsubToken.Token, manageToken.Token)
+		if err != nil {
+			return false, err
+		}
+
+		return outbox.EnqueueOnce(m.state, slotKey(subType, slot, subscription), mail.MailMessage{
+			To:      []string{subscription.User.Email},
+			Subject: locale.Sprintf("subject."+string(subType), subscription.City.DisplayName()),
+			Body:    body,
+			Headers: m.unsubscribeHeaders(unsubToken.Token),
+		})
+	})
+}
+
+// unsubscribeHeaders return List-Unsubscribe headers of subscription emails, mail clients which support
+// RFC 8058 unsubscribe in one click by posting to the link
+func (m *Manager) unsubscribeHeaders(unsubToken string) map[string]string {
+	return map[string]string{
+		"List-Unsubscribe":      "<" + templates.UnsubscribeLink(m.cfg.FrontendURL, unsubToken) + ">",
+		"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
 	}
+}
 
-	err = m.sendMail(subscriptions, models.DAILY)
+// subscriptionTokens returns tokens linked from subscription emails, manage token is issued
+// for subscriptions created before it was introduced and unsigned unsub token is replaced
+func (m *Manager) subscriptionTokens(subscription *models.Subscription) (unsub, manage *models.Token, err error) {
+	unsub, err = subscriptions.UnsubToken(m.state, m.cfg.ManageTokenSecret, subscription.ID)
 	if err != nil {
-		return err
+		return nil, nil, err
+	}
+	manage, err = subscriptions.ManageToken(m.state, m.cfg.ManageTokenSecret, subscription.ID)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	return nil
+	return unsub, manage, nil
 }
 
-func (m *Manager) sendMail(subscriptions []*models.Subscription, subType models.SubscriptionType) error {
-	wg := sync.WaitGroup{}
-	for i := range subscriptions {
-		weather, err := m.getWeatherForSubscription(subscriptions[i])
-		if err != nil {
-			zap.L().Error("failed to get weather for subscription", zap.Error(err))
-			return err
+// slotKey identifies email of the subscription queued in the job slot
+func slotKey(subType models.SubscriptionType, slot time.Time, subscription *models.Subscription) string {
+	return fmt.Sprintf("%s:%s:%s", subType, slot.UTC().Format(time.RFC3339), subscription.ID)
+}
+
+// subscriberLocale returns locale emails of the subscription are rendered in
+func subscriberLocale(subscription *models.Subscription) *templates.Locale {
+	return templates.NewLocale(subscription.User.Language, models.Units(subscription.User.Units))
+}
+
+// subscriberLanguage returns language city data is fetched in for the subscription
+func subscriberLanguage(subscription *models.Subscription) string {
+	return subscriberLocale(subscription).Language
+}
+
+// processBatch fetches weather once per city and language of the subscriptions and calls handle for every subscription.
+// handle reports whether an email was enqueued
+func (m *Manager) processBatch(
+	subscriptions []*models.Subscription,
+	handle func(subscription *models.Subscription, weather *models.Weather) (bool, error),
+) BatchStats {
+	return processCities(subscriptions, m.getWeatherForCities, handle)
+}
+
+// cityKey identifies data of a city fetched in a language
+type cityKey struct {
+	cityID   string
+	language string
+}
+
+// processCities loads data once per city and language of the subscriptions and calls handle for every
+// subscription which city data was loaded
+func processCities[T any](
+	subscriptions []*models.Subscription,
+	load func(cities map[cityKey][]*models.Subscription) map[cityKey]T,
+	handle func(subscription *models.Subscription, data T) (bool, error),
+) BatchStats {
+	cities := make(map[cityKey][]*models.Subscription)
+	for _, subscription := range subscriptions {
+		key := cityKey{cityID: subscription.CityID, language: subscriberLanguage(subscription)}
+		cities[key] = append(cities[key], subscription)
+	}
+	data := load(cities)
+
+	var stats BatchStats
+	for key, citySubscriptions := range cities {
+		cityData, ok := data[key]
+		if !ok {
+			stats.Failed += len(citySubscriptions)
+			continue
 		}
-		unsubToken, err := m.state.GetUnsubToken(subscriptions[i].User.ID)
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			err = m.mailer.Send(mail.MailMessage{
-				To:      []string{subscriptions[i].User.Email},
-				Subject: fmt.Sprintf("Your %s weather", strings.ToLower(string(subType))),
-				Body:    templates.GetWeatherEmailBody(weather, m.cfg.FrontendURL, unsubToken.Token),
-			})
-			if err != nil {
-				zap.L().Error("Error sending email", zap.Error(err))
+
+		for _, subscription := range citySubscriptions {
+			sent, err := handle(subscription, cityData)
+			switch {
+			case err != nil:
+				zap.L().Error("failed to process subscription", zap.String("id", subscription.ID), zap.Error(err))
+				stats.Failed++
+			case sent:
+				stats.Sent++
+			default:
+				stats.Skipped++
 			}
-		}()
+		}
 	}
-	wg.Wait()
 
-	return nil
+	return stats
 }
 
-func (m *Manager) getWeatherForSubscription(subscription *models.Subscription) (*models.Weather, error) {
-	weather, err := m.state.GetWeather(subscription.User.CityID)
-	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
-		return nil, err
+// getWeatherForCities returns recent weather for the cities, cities which weather could not be fetched are omitted
+func (m *Manager) getWeatherForCities(cities map[cityKey][]*models.Subscription) map[cityKey]*models.Weather {
+	return loadCities(m, cities, cityLoader[*models.Weather]{
+		stored: m.state.GetWeather,
+		fresh: func(weather *models.Weather) bool {
+			return weather.Time.After(time.Now().Add(-weatherLifetime))
+		},
+		fetch: func(ctx context.Context, city *models.City) (*models.Weather, error) {
+			return m.weatherIntegration.GetWeather(ctx, city)
+		},
+		save: m.state.SaveWeather,
+	})
+}
+
+// cityLoader describes how data of a city is read from state, fetched from integration and saved
+type cityLoader[T any] struct {
+	stored func(cityID, language string) (T, error)
+	fresh  func(data T) bool
+	fetch  func(ctx context.Context, city *models.City) (T, error)
+	save   func(data T) error
+}
+
+// outdatedCity is a city which data has to be fetched in the language
+type outdatedCity struct {
+	key  cityKey
+	city *models.City
+}
+
+// loadCities returns data of the cities, missing or outdated data is fetched by configured number of
+// concurrent workers. Data is fetched in metric units, emails convert it to subscriber units. State is
+// accessed on the calling goroutine only. Cities which data could not be loaded are omitted
+func loadCities[T any](m *Manager, cities map[cityKey][]*models.Subscription, loader cityLoader[T]) map[cityKey]T {
+	result := make(map[cityKey]T, len(cities))
+	var outdated []outdatedCity
+	for key, subscriptions := range cities {
+		data, err := loader.stored(key.cityID, key.language)
+		if err != nil && !errors.Is(err, state.ErrNotFound) {
+			zap.L().Error("failed to get city data", zap.String("city", key.cityID), zap.Error(err))
+			continue
+		}
+		if err != nil || !loader.fresh(data) {
+			city := subscriptions[0].City
+			outdated = append(outdated, outdatedCity{key: key, city: &city})
+			continue
+		}
+		result[key] = data
 	}
-	if weather == nil || weather.Time.Before(time.Now().Add(-weatherLifetime)) {
-		city, err := m.state.GetCityByID(subscription.User.CityID)
+
+	mu := sync.Mutex{}
+	workers.Run(m.cfg.WeatherWorkers, outdated, func(outdated outdatedCity) {
+		ctx := integrations.WithPreferences(m.ctx, integrations.Preferences{
+			Units:    models.Metric,
+			Language: outdated.key.language,
+		})
+		data, err := loader.fetch(ctx, outdated.city)
 		if err != nil {
-			return nil, err
+			zap.L().Error("failed to fetch city data", zap.String("city", outdated.city.Name), zap.Error(err))
+			return
 		}
-		weather, err = m.weatherIntegration.GetWeather(m.ctx, city)
-		if err != nil {
-			return nil, err
+		mu.Lock()
+		result[outdated.key] = data
+		mu.Unlock()
+	})
+
+	for _, city := range outdated {
+		data, ok := result[city.key]
+		if !ok {
+			continue
+		}
+		if err := loader.save(data); err != nil {
+			zap.L().Error("failed to save city data", zap.Error(err))
 		}
 	}
 
-	return weather, nil
+	return result
+}
+
+func logStats(subType models.SubscriptionType, stats BatchStats) {
+	zap.L().Info(
+		"mail batch processed",
+		zap.String("type", string(subType)),
+		zap.Int("sent", stats.Sent),
+		zap.Int("failed", stats.Failed),
+		zap.Int("skipped", stats.Skipped),
+	)
 }
 
 func New(
 	ctx context.Context,
 	cfg *config.Config,
 	state state.Stateful,
-	mailer mail.MailerService,
+	weatherIntegration integrations.MapsIntegration,
 ) MailManager {
-	googleInt := google.New(cfg)
 	return &Manager{
 		cfg:                cfg,
 		state:              state,
-		mailer:             mailer,
 		ctx:                ctx,
-		weatherIntegration: googleInt,
+		weatherIntegration: weatherIntegration,
 	}
 }
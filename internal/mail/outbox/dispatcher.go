@@ -0,0 +1,138 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"go.uber.org/zap"
+	"time"
+	"weather-subscriptions/internal/config"
+	"weather-subscriptions/internal/db/models"
+	mail "weather-subscriptions/internal/mail/mailer_service"
+	"weather-subscriptions/internal/state"
+	"weather-subscriptions/internal/suppressions"
+	"weather-subscriptions/internal/workers"
+)
+
+var errSuppressed = errors.New("all recipients are suppressed")
+
+// Dispatcher delivers outbox messages, failed deliveries are retried with exponential backoff
+// until the max attempts are reached and the message is marked dead. Messages to addresses suppressed
+// after they were queued are marked dead without sending
+type Dispatcher struct {
+	cfg          *config.Config
+	state        state.Stateful
+	mailer       mail.MailerService
+	suppressions suppressions.SuppressionManager
+}
+
+func NewDispatcher(cfg *config.Config, state state.Stateful, mailer mail.MailerService) *Dispatcher {
+	return &Dispatcher{
+		cfg:          cfg,
+		state:        state,
+		mailer:       mailer,
+		suppressions: suppressions.New(state),
+	}
+}
+
+// Run dispatches outbox batches every poll interval until context is done
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.cfg.Outbox.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := d.Dispatch(); err != nil {
+				zap.L().Error("failed to dispatch outbox", zap.Error(err))
+			}
+		}
+	}
+}
+
+// Dispatch delivers a single batch of due messages using configured number of workers. Messages are
+// claimed for the lease first, so several dispatchers never deliver the same message while no rows
+// stay locked during delivery, and every message is updated on its own once it is delivered or failed
+func (d *Dispatcher) Dispatch() error {
+	now := time.Now()
+	messages, err := d.state.ClaimOutboxMessages(now, now.Add(d.cfg.Outbox.Lease), d.cfg.Outbox.BatchSize)
+	if err != nil {
+		return err
+	}
+
+	workers.Run(d.cfg.Outbox.Workers, messages, d.deliver)
+
+	return nil
+}
+
+// deliver sends the claimed message and updates it, its attempt is counted by the claim
+func (d *Dispatcher) deliver(message *models.OutboxMessage) {
+	defer d.save(message)
+
+	to, err := d.unsuppressed(message.To)
+	if err == nil && len(to) == 0 {
+		message.Status = string(models.OutboxDead)
+		message.LastError = errSuppressed.Error()
+		zap.L().Info("outbox message to suppressed address is dead", zap.String("id", message.ID))
+		return
+	}
+	if err == nil {
+		err = d.mailer.Send(mail.MailMessage{
+			To:      to,
+			Subject: message.Subject,
+			Body:    message.Body,
+			Text:    message.Text,
+			Headers: message.Headers,
+		})
+	}
+	if err == nil {
+		now := time.Now()
+		message.Status = string(models.OutboxSent)
+		message.SentAt = &now
+		message.LastError = ""
+		return
+	}
+
+	message.LastError = err.Error()
+	if message.Attempts >= d.cfg.Outbox.MaxAttempts {
+		message.Status = string(models.OutboxDead)
+		zap.L().Error("outbox message is dead", zap.String("id", message.ID), zap.Error(err))
+		return
+	}
+	message.NextAttemptAt = time.Now().Add(d.backoff(message.Attempts))
+	zap.L().Warn("failed to deliver outbox message", zap.String("id", message.ID), zap.Error(err))
+}
+
+// unsuppressed returns recipients whose addresses are not suppressed, message is not sent when check fails
+func (d *Dispatcher) unsuppressed(recipients []string) ([]string, error) {
+	to := make([]string, 0, len(recipients))
+	for _, recipient := range recipients {
+		suppressed, err := d.suppressions.IsSuppressed(recipient)
+		if err != nil {
+			return nil, err
+		}
+		if !suppressed {
+			to = append(to, recipient)
+		}
+	}
+
+	return to, nil
+}
+
+// save updates the delivered message, message which could not be updated is retried after the lease
+func (d *Dispatcher) save(message *models.OutboxMessage) {
+	err := d.state.SaveOutboxMessage(message)
+	if err != nil {
+		zap.L().Error("failed to update outbox message", zap.String("id", message.ID), zap.Error(err))
+	}
+}
+
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	delay := d.cfg.Outbox.BaseBackoff
+	for i := 1; i < attempts && delay < d.cfg.Outbox.MaxBackoff; i++ {
+		delay *= 2
+	}
+
+	return min(delay, d.cfg.Outbox.MaxBackoff)
+}
@@ -0,0 +1,42 @@
+package outbox
+
+import (
+	"github.com/google/uuid"
+	"time"
+	"weather-subscriptions/internal/db/models"
+	mail "weather-subscriptions/internal/mail/mailer_service"
+	"weather-subscriptions/internal/state"
+)
+
+// Enqueue stores message in the outbox to be delivered by Dispatcher.
+// Pass transaction state to commit the message together with the change which caused it.
+// Plain-text alternative is generated from the html body unless message has one
+func Enqueue(state state.Stateful, message mail.MailMessage) error {
+	return state.SaveOutboxMessage(newMessage(message))
+}
+
+// EnqueueOnce stores message like Enqueue unless a message with the same key was stored already,
+// it reports whether the message was stored
+func EnqueueOnce(state state.Stateful, key string, message mail.MailMessage) (bool, error) {
+	outboxMessage := newMessage(message)
+	outboxMessage.DedupKey = &key
+
+	return state.CreateOutboxMessage(outboxMessage)
+}
+
+func newMessage(message mail.MailMessage) *models.OutboxMessage {
+	if message.Text == "" {
+		message.Text = mail.PlainText(message.Body)
+	}
+
+	return &models.OutboxMessage{
+		ID:            uuid.Must(uuid.NewV7()).String(),
+		To:            message.To,
+		Subject:       message.Subject,
+		Body:          message.Body,
+		Text:          message.Text,
+		Headers:       message.Headers,
+		Status:        string(models.OutboxPending),
+		NextAttemptAt: time.Now(),
+	}
+}
@@ -0,0 +1,23 @@
+package mail
+
+import "time"
+
+// DailyCheckInterval is how often daily delivery is checked, SendDaily must be given slots of the same interval
+const DailyCheckInterval = 15 * time.Minute
+
+// dueHours returns local delivery hours which are due at the given moment in the location.
+// Delivery time is resolved on the local calendar date, so DST shifts are handled by time.Date:
+// an hour skipped by spring-forward is delivered together with the next one,
+// and an hour repeated by fall-back resolves to a single instant
+func dueHours(now time.Time, location *time.Location) []int {
+	local := now.In(location)
+	var hours []int
+	for hour := 0; hour < 24; hour++ {
+		deliveryTime := time.Date(local.Year(), local.Month(), local.Day(), hour, 0, 0, 0, location)
+		if !now.Before(deliveryTime) && now.Sub(deliveryTime) < DailyCheckInterval {
+			hours = append(hours, hour)
+		}
+	}
+
+	return hours
+}
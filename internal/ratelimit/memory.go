@@ -0,0 +1,52 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+	"weather-subscriptions/internal/db/models"
+)
+
+// pruneInterval is how often stores remove full buckets
+const pruneInterval = 10 * time.Minute
+
+// MemoryStore keeps buckets in process memory, every replica limits requests it receives separately
+type MemoryStore struct {
+	mu       sync.Mutex
+	buckets  map[string]*models.RateLimit
+	prunedAt time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		buckets:  make(map[string]*models.RateLimit),
+		prunedAt: clock(),
+	}
+}
+
+func (s *MemoryStore) Take(key string, limit Limit) (time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := clock()
+	s.prune(now)
+	bucket, ok := s.buckets[key]
+	if !ok {
+		bucket = fullBucket(key, limit, now)
+		s.buckets[key] = bucket
+	}
+
+	return take(bucket, limit, now), nil
+}
+
+// prune removes full buckets once per pruneInterval, a missing bucket is the same as a full one
+func (s *MemoryStore) prune(now time.Time) {
+	if now.Sub(s.prunedAt) < pruneInterval {
+		return
+	}
+	s.prunedAt = now
+	for key, bucket := range s.buckets {
+		if bucket.FullAt.Before(now) {
+			delete(s.buckets, key)
+		}
+	}
+}
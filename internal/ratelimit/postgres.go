@@ -0,0 +1,57 @@
+package ratelimit
+
+import (
+	"go.uber.org/zap"
+	"sync"
+	"time"
+	"weather-subscriptions/internal/state"
+)
+
+// PostgresStore keeps buckets in rate_limits table, so limits are shared by all replicas.
+// Bucket row is locked while a token is taken from it
+type PostgresStore struct {
+	state    state.Stateful
+	mu       sync.Mutex
+	prunedAt time.Time
+}
+
+func NewPostgresStore(state state.Stateful) *PostgresStore {
+	return &PostgresStore{state: state}
+}
+
+func (s *PostgresStore) Take(key string, limit Limit) (time.Duration, error) {
+	now := clock()
+	s.prune(now)
+
+	var retryAfter time.Duration
+	err := s.state.Transaction(func(tx state.Stateful) error {
+		bucket, err := tx.LockRateLimit(fullBucket(key, limit, now))
+		if err != nil {
+			return err
+		}
+		retryAfter = take(bucket, limit, now)
+
+		return tx.SaveRateLimit(bucket)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return retryAfter, nil
+}
+
+// prune removes full buckets in background once per pruneInterval of this replica
+func (s *PostgresStore) prune(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if now.Sub(s.prunedAt) < pruneInterval {
+		return
+	}
+	s.prunedAt = now
+
+	go func() {
+		if err := s.state.RemoveRateLimits(now); err != nil {
+			zap.L().Warn("failed to prune rate limits", zap.Error(err))
+		}
+	}()
+}
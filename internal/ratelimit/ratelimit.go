@@ -0,0 +1,153 @@
+package ratelimit
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+	"weather-subscriptions/internal/config"
+	"weather-subscriptions/internal/db/models"
+	"weather-subscriptions/internal/state"
+)
+
+const (
+	Memory   = "memory"
+	Postgres = "postgres"
+)
+
+var ErrRateLimited = errors.New("too many requests, try again later")
+
+// clock returns the current time of buckets, tests replace it to refill them
+var clock = time.Now
+
+// ExceededError is returned when a limit is exceeded, it matches ErrRateLimited
+type ExceededError struct {
+	// Scope is the exceeded limit, e.g. "ip"
+	Scope      string
+	RetryAfter time.Duration
+}
+
+func (e *ExceededError) Error() string {
+	return fmt.Sprintf("%s rate limit exceeded, retry after %s", e.Scope, e.RetryAfter)
+}
+
+func (e *ExceededError) Is(target error) bool {
+	return target == ErrRateLimited
+}
+
+// Limit allows Requests per Period with bursts of up to Requests, it is disabled when Requests is 0
+type Limit struct {
+	Requests int
+	Period   time.Duration
+}
+
+func (l Limit) disabled() bool {
+	return l.Requests <= 0 || l.Period <= 0
+}
+
+// rate returns tokens added to a bucket per second
+func (l Limit) rate() float64 {
+	return float64(l.Requests) / l.Period.Seconds()
+}
+
+// Store keeps token buckets of rate limit keys
+type Store interface {
+	// Take takes a token from the bucket of the key, it returns time until the next token
+	// when the bucket is empty and zero otherwise
+	Take(key string, limit Limit) (time.Duration, error)
+}
+
+// Limiter applies configured limits of POST /subscribe
+type Limiter struct {
+	store   Store
+	ip      Limit
+	email   Limit
+	newCity Limit
+	search  Limit
+}
+
+// New creates limiter with the store selected by RATE_LIMIT_STORE config key
+func New(cfg *config.Config, state state.Stateful) (*Limiter, error) {
+	var store Store
+	switch cfg.RateLimit.Store {
+	case Memory:
+		store = NewMemoryStore()
+	case Postgres:
+		store = NewPostgresStore(state)
+	default:
+		return nil, fmt.Errorf("unknown rate limit store: '%s'", cfg.RateLimit.Store)
+	}
+
+	return &Limiter{
+		store:   store,
+		ip:      Limit{Requests: cfg.RateLimit.IPRequests, Period: cfg.RateLimit.IPPeriod},
+		email:   Limit{Requests: cfg.RateLimit.EmailRequests, Period: cfg.RateLimit.EmailPeriod},
+		newCity: Limit{Requests: cfg.RateLimit.NewCityRequests, Period: cfg.RateLimit.NewCityPeriod},
+		search:  Limit{Requests: cfg.RateLimit.SearchRequests, Period: cfg.RateLimit.SearchPeriod},
+	}, nil
+}
+
+// AllowIP takes a request of the client IP
+func (l *Limiter) AllowIP(ip string) error {
+	return l.allow("ip", ip, l.ip)
+}
+
+// AllowEmail takes a request to the email address, addresses differing in case share the limit
+func (l *Limiter) AllowEmail(email string) error {
+	return l.allow("email", strings.ToLower(strings.TrimSpace(email)), l.email)
+}
+
+// AllowNewCity takes a city of the client IP which is not known yet and has to be geocoded
+func (l *Limiter) AllowNewCity(ip string) error {
+	return l.allow("new-city", ip, l.newCity)
+}
+
+// AllowSearch takes a city search query of the client IP which is not known yet and has to be geocoded
+func (l *Limiter) AllowSearch(ip string) error {
+	return l.allow("search", ip, l.search)
+}
+
+func (l *Limiter) allow(scope, key string, limit Limit) error {
+	if limit.disabled() {
+		return nil
+	}
+	retryAfter, err := l.store.Take(scope+":"+key, limit)
+	if err != nil {
+		return err
+	}
+	if retryAfter > 0 {
+		return &ExceededError{Scope: scope, RetryAfter: retryAfter}
+	}
+
+	return nil
+}
+
+// take refills the bucket for the time passed since its last refill and takes a token from it.
+// Zero is returned when the token is taken, otherwise the time until the next token
+func take(bucket *models.RateLimit, limit Limit, now time.Time) time.Duration {
+	rate := limit.rate()
+	capacity := float64(limit.Requests)
+	elapsed := max(now.Sub(bucket.RefilledAt).Seconds(), 0)
+	bucket.Tokens = math.Min(capacity, bucket.Tokens+elapsed*rate)
+	bucket.RefilledAt = now
+
+	var retryAfter time.Duration
+	if bucket.Tokens >= 1 {
+		bucket.Tokens--
+	} else {
+		retryAfter = seconds((1 - bucket.Tokens) / rate)
+	}
+	bucket.FullAt = now.Add(seconds((capacity - bucket.Tokens) / rate))
+
+	return retryAfter
+}
+
+// fullBucket returns bucket of the key which has not been used yet
+func fullBucket(key string, limit Limit, now time.Time) *models.RateLimit {
+	return &models.RateLimit{Key: key, Tokens: float64(limit.Requests), RefilledAt: now, FullAt: now}
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(math.Ceil(s * float64(time.Second)))
+}
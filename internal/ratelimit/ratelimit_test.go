@@ -0,0 +1,124 @@
+package ratelimit
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+	"weather-subscriptions/internal/db/models"
+)
+
+var testNow = time.Date(2025, time.June, 14, 7, 30, 0, 0, time.UTC)
+
+// fakeClock replaces clock of the package for the test, returned function moves it forward
+func fakeClock(t *testing.T) func(d time.Duration) {
+	t.Helper()
+
+	previous := clock
+	t.Cleanup(func() { clock = previous })
+	now := testNow
+	clock = func() time.Time { return now }
+
+	return func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestTake(t *testing.T) {
+	// 6 requests per minute refill a token every 10 seconds
+	limit := Limit{Requests: 6, Period: time.Minute}
+	tests := []struct {
+		name       string
+		tokens     float64
+		elapsed    time.Duration
+		retryAfter time.Duration
+		left       float64
+		full       time.Duration
+	}{
+		{name: "full bucket", tokens: 6, left: 5, full: 10 * time.Second},
+		{name: "last token", tokens: 1, left: 0, full: time.Minute},
+		{name: "empty bucket", tokens: 0, retryAfter: 10 * time.Second, left: 0, full: time.Minute},
+		{name: "partly refilled bucket", tokens: 0.5, retryAfter: 5 * time.Second, left: 0.5, full: 55 * time.Second},
+		{name: "refilled by elapsed time", tokens: 0, elapsed: 25 * time.Second, left: 1.5, full: 45 * time.Second},
+		{name: "refill is capped at capacity", tokens: 2, elapsed: time.Hour, left: 5, full: 10 * time.Second},
+		{name: "clock moved backwards", tokens: 0, elapsed: -time.Minute, retryAfter: 10 * time.Second, full: time.Minute},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			bucket := &models.RateLimit{Key: "ip:203.0.113.10", Tokens: test.tokens, RefilledAt: testNow}
+			now := testNow.Add(test.elapsed)
+
+			retryAfter := take(bucket, limit, now)
+
+			assert.Equal(t, test.retryAfter, retryAfter)
+			assert.InDelta(t, test.left, bucket.Tokens, 1e-9)
+			assert.Equal(t, now, bucket.RefilledAt)
+			assert.Equal(t, now.Add(test.full), bucket.FullAt)
+		})
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	advance := fakeClock(t)
+	store := NewMemoryStore()
+	limit := Limit{Requests: 2, Period: time.Minute}
+
+	for range 2 {
+		retryAfter, err := store.Take("ip:203.0.113.10", limit)
+		require.NoError(t, err)
+		assert.Zero(t, retryAfter, "burst of the limit is allowed")
+	}
+	retryAfter, err := store.Take("ip:203.0.113.10", limit)
+	require.NoError(t, err)
+	assert.Equal(t, 30*time.Second, retryAfter, "empty bucket waits for the next token")
+
+	retryAfter, err = store.Take("ip:198.51.100.7", limit)
+	require.NoError(t, err)
+	assert.Zero(t, retryAfter, "other keys have their own bucket")
+
+	advance(20 * time.Second)
+	retryAfter, err = store.Take("ip:203.0.113.10", limit)
+	require.NoError(t, err)
+	assert.InDelta(t, 10*time.Second, retryAfter, float64(time.Microsecond), "rejected request does not take a token")
+
+	advance(10 * time.Second)
+	retryAfter, err = store.Take("ip:203.0.113.10", limit)
+	require.NoError(t, err)
+	assert.Zero(t, retryAfter, "refilled token is taken")
+}
+
+func TestMemoryStorePrunesFullBuckets(t *testing.T) {
+	advance := fakeClock(t)
+	store := NewMemoryStore()
+	limit := Limit{Requests: 2, Period: time.Minute}
+
+	_, err := store.Take("ip:203.0.113.10", limit)
+	require.NoError(t, err)
+	advance(pruneInterval)
+	_, err = store.Take("ip:198.51.100.7", limit)
+	require.NoError(t, err)
+
+	assert.NotContains(t, store.buckets, "ip:203.0.113.10", "full bucket is pruned")
+	assert.Contains(t, store.buckets, "ip:198.51.100.7")
+}
+
+func TestLimiter(t *testing.T) {
+	fakeClock(t)
+	limiter := &Limiter{
+		store: NewMemoryStore(),
+		email: Limit{Requests: 1, Period: time.Hour},
+		ip:    Limit{Requests: 1, Period: time.Minute},
+	}
+
+	require.NoError(t, limiter.AllowEmail("User@Example.org"))
+	err := limiter.AllowEmail(" user@example.org")
+	var exceeded *ExceededError
+	require.ErrorAs(t, err, &exceeded, "addresses differing in case share the limit")
+	assert.Equal(t, "email", exceeded.Scope)
+	assert.Equal(t, time.Hour, exceeded.RetryAfter)
+	assert.ErrorIs(t, err, ErrRateLimited)
+
+	require.NoError(t, limiter.AllowIP("user@example.org"), "scopes have separate buckets")
+	for range 3 {
+		assert.NoError(t, limiter.AllowNewCity("203.0.113.10"), "disabled limit allows every request")
+	}
+}
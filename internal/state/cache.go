@@ -0,0 +1,135 @@
+package state
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// CacheStats holds counters of a single entity cache
+type CacheStats struct {
+	Hits   uint64
+	Misses uint64
+	Size   int
+}
+
+// cache is a goroutine-safe LRU cache with per-entry expiration.
+// Least recently used entry is evicted once the cache reaches its size
+type cache[V any] struct {
+	mu     sync.Mutex
+	ttl    time.Duration
+	size   int
+	items  map[string]*list.Element
+	order  *list.List
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+type cacheEntry[V any] struct {
+	key       string
+	value     V
+	expiresAt time.Time
+}
+
+func newCache[V any](ttl time.Duration, size int) *cache[V] {
+	return &cache[V]{
+		ttl:   ttl,
+		size:  size,
+		items: make(map[string]*list.Element),
+		order: list.New(),
+	}
+}
+
+func (c *cache[V]) Get(key string) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.items[key]
+	if !ok {
+		c.misses.Add(1)
+		var empty V
+		return empty, false
+	}
+	entry := element.Value.(*cacheEntry[V])
+	if time.Now().After(entry.expiresAt) {
+		c.remove(element)
+		c.misses.Add(1)
+		var empty V
+		return empty, false
+	}
+	c.order.MoveToFront(element)
+	c.hits.Add(1)
+
+	return entry.value, true
+}
+
+func (c *cache[V]) Set(key string, value V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expiresAt := time.Now().Add(c.ttl)
+	if element, ok := c.items[key]; ok {
+		entry := element.Value.(*cacheEntry[V])
+		entry.value = value
+		entry.expiresAt = expiresAt
+		c.order.MoveToFront(element)
+		return
+	}
+
+	c.items[key] = c.order.PushFront(&cacheEntry[V]{key: key, value: value, expiresAt: expiresAt})
+	for c.size > 0 && c.order.Len() > c.size {
+		c.remove(c.order.Back())
+	}
+}
+
+func (c *cache[V]) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.items[key]; ok {
+		c.remove(element)
+	}
+}
+
+// DeleteFunc removes every entry for which fn returns true
+func (c *cache[V]) DeleteFunc(fn func(key string, value V) bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key, element := range c.items {
+		if fn(key, element.Value.(*cacheEntry[V]).value) {
+			c.remove(element)
+		}
+	}
+}
+
+// CopyTo sets all not expired entries to the other cache
+func (c *cache[V]) CopyTo(other *cache[V]) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	for element := c.order.Back(); element != nil; element = element.Prev() {
+		entry := element.Value.(*cacheEntry[V])
+		if now.Before(entry.expiresAt) {
+			other.Set(entry.key, entry.value)
+		}
+	}
+}
+
+func (c *cache[V]) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return CacheStats{
+		Hits:   c.hits.Load(),
+		Misses: c.misses.Load(),
+		Size:   c.order.Len(),
+	}
+}
+
+func (c *cache[V]) remove(element *list.Element) {
+	c.order.Remove(element)
+	delete(c.items, element.Value.(*cacheEntry[V]).key)
+}
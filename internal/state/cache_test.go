@@ -0,0 +1,280 @@
+package state
+
+import (
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+	"weather-subscriptions/internal/config"
+	"weather-subscriptions/internal/db/models"
+	"weather-subscriptions/internal/state/resolvers"
+)
+
+// Tests are meant to run with -race, concurrent ones only assert what holds for any interleaving
+
+const (
+	goroutines          = 16
+	operationsPerWorker = 1000
+)
+
+func TestCacheGetSet(t *testing.T) {
+	c := newCache[int](time.Minute, 10)
+
+	_, ok := c.Get("a")
+	assert.False(t, ok)
+	c.Set("a", 1)
+	value, ok := c.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, 1, value)
+	c.Set("a", 2)
+	value, _ = c.Get("a")
+	assert.Equal(t, 2, value)
+	c.Delete("a")
+	_, ok = c.Get("a")
+	assert.False(t, ok)
+
+	assert.Equal(t, CacheStats{Hits: 2, Misses: 2, Size: 0}, c.Stats())
+}
+
+func TestCacheExpiry(t *testing.T) {
+	c := newCache[int](20*time.Millisecond, 10)
+	c.Set("a", 1)
+
+	_, ok := c.Get("a")
+	require.True(t, ok)
+	time.Sleep(40 * time.Millisecond)
+	_, ok = c.Get("a")
+	assert.False(t, ok, "expired entry is a miss")
+	assert.Equal(t, CacheStats{Hits: 1, Misses: 1, Size: 0}, c.Stats(), "expired entry is removed once read")
+
+	// CopyTo skips expired entries
+	c.Set("b", 2)
+	time.Sleep(40 * time.Millisecond)
+	other := newCache[int](time.Minute, 10)
+	c.CopyTo(other)
+	assert.Equal(t, 0, other.Stats().Size)
+}
+
+func TestCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	c := newCache[int](time.Minute, 2)
+	c.Set("a", 1)
+	c.Set("b", 2)
+	_, _ = c.Get("a")
+	c.Set("c", 3)
+
+	_, ok := c.Get("b")
+	assert.False(t, ok, "least recently used entry is evicted")
+	_, ok = c.Get("a")
+	assert.True(t, ok)
+	_, ok = c.Get("c")
+	assert.True(t, ok)
+	assert.Equal(t, 2, c.Stats().Size)
+}
+
+func TestCacheDeleteFunc(t *testing.T) {
+	c := newCache[int](time.Minute, 10)
+	for i := range 6 {
+		c.Set(strconv.Itoa(i), i)
+	}
+
+	c.DeleteFunc(func(_ string, value int) bool { return value%2 == 0 })
+
+	assert.Equal(t, 3, c.Stats().Size)
+	_, ok := c.Get("1")
+	assert.True(t, ok)
+	_, ok = c.Get("2")
+	assert.False(t, ok)
+}
+
+func TestCacheConcurrent(t *testing.T) {
+	const size = 50
+	c := newCache[int](time.Minute, size)
+	other := newCache[int](time.Minute, size)
+	var gets atomic.Uint64
+
+	var wg sync.WaitGroup
+	for worker := range goroutines {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range operationsPerWorker {
+				key := strconv.Itoa((worker*operationsPerWorker + i) % (2 * size))
+				switch i % 5 {
+				case 0, 1:
+					c.Set(key, i)
+				case 2:
+					c.Delete(key)
+				case 3:
+					c.DeleteFunc(func(k string, _ int) bool { return k == key })
+				default:
+					c.CopyTo(other)
+				}
+				_, _ = c.Get(key)
+				gets.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	stats := c.Stats()
+	assert.Equal(t, gets.Load(), stats.Hits+stats.Misses, "every get is a hit or a miss")
+	assert.LessOrEqual(t, stats.Size, size)
+	assert.LessOrEqual(t, other.Stats().Size, size)
+}
+
+// stubResolver keeps users in memory and counts lookups, other resolver methods are not used
+type stubResolver struct {
+	resolvers.Resolver
+	mu      sync.Mutex
+	users   map[string]*models.User
+	lookups atomic.Uint64
+}
+
+func newStubResolver() *stubResolver {
+	return &stubResolver{users: make(map[string]*models.User)}
+}
+
+func (r *stubResolver) UserByID(id string) (*models.User, error) {
+	r.lookups.Add(1)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, user := range r.users {
+		if user.ID == id {
+			return user, nil
+		}
+	}
+
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *stubResolver) UserByEmail(email string) (*models.User, error) {
+	r.lookups.Add(1)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[email]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	return user, nil
+}
+
+func (r *stubResolver) Save(model any) error {
+	user, ok := model.(*models.User)
+	if !ok {
+		return fmt.Errorf("unexpected model %T", model)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.users[user.Email] = user
+
+	return nil
+}
+
+func (r *stubResolver) Remove(model any) error {
+	user, ok := model.(*models.User)
+	if !ok {
+		return fmt.Errorf("unexpected model %T", model)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.users, user.Email)
+
+	return nil
+}
+
+func (r *stubResolver) Transaction(fn func(resolver resolvers.Resolver) error) error {
+	return fn(r)
+}
+
+func newTestState(resolver resolvers.Resolver) *State {
+	cfg := &config.Config{}
+	cfg.Cache.Size = 100
+	cfg.Cache.UserTTL = time.Minute
+	cfg.Cache.CityTTL = time.Minute
+	cfg.Cache.WeatherTTL = time.Minute
+	cfg.Cache.ForecastTTL = time.Minute
+	cfg.Cache.TokenTTL = time.Minute
+	cfg.Cache.SubscriptionTTL = time.Minute
+	cfg.Cache.SearchTTL = time.Minute
+
+	return newState(cfg, resolver)
+}
+
+func TestStateCachesUsers(t *testing.T) {
+	resolver := newStubResolver()
+	st := newTestState(resolver)
+	user := &models.User{ID: "1", Email: "user@example.com"}
+	resolver.users[user.Email] = user
+
+	for range 3 {
+		found, err := st.GetUser("1")
+		require.NoError(t, err)
+		assert.Same(t, user, found)
+	}
+	_, err := st.GetUser("2")
+	assert.True(t, errors.Is(err, ErrNotFound))
+
+	assert.Equal(t, uint64(2), resolver.lookups.Load(), "only misses reach the resolver")
+	stats := st.CacheStats()["users"]
+	assert.Equal(t, uint64(2), stats.Hits)
+	assert.Equal(t, uint64(2), stats.Misses)
+
+	require.NoError(t, st.RemoveUser(user))
+	_, err = st.GetUser("1")
+	assert.ErrorIs(t, err, ErrNotFound, "removed user is evicted")
+}
+
+func TestStateConcurrent(t *testing.T) {
+	const users = 20
+	resolver := newStubResolver()
+	st := newTestState(resolver)
+	var gets atomic.Uint64
+
+	var wg sync.WaitGroup
+	for worker := range goroutines {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range operationsPerWorker {
+				n := (worker + i) % users
+				user := &models.User{ID: strconv.Itoa(n), Email: fmt.Sprintf("user%d@example.com", n)}
+				var err error
+				switch i % 6 {
+				case 0:
+					err = st.SaveUser(user)
+				case 1:
+					err = st.RemoveUser(user)
+				case 2:
+					err = st.Transaction(func(tx Stateful) error {
+						return tx.SaveUser(user)
+					})
+				case 3:
+					_, err = st.GetUserByEmail(user.Email)
+					gets.Add(1)
+				default:
+					_, err = st.GetUser(user.ID)
+					gets.Add(1)
+				}
+				if err != nil && !errors.Is(err, ErrNotFound) {
+					t.Errorf("unexpected error: %v", err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	stats := st.CacheStats()["users"]
+	assert.Equal(t, gets.Load(), stats.Hits+stats.Misses, "every get is a hit or a miss")
+	assert.Equal(t, stats.Misses, resolver.lookups.Load(), "every miss reaches the resolver once")
+	assert.LessOrEqual(t, stats.Size, 100)
+}
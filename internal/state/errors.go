@@ -0,0 +1,19 @@
+package state
+
+import (
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+)
+
+// ErrNotFound is returned by getters of a single entity which does not exist
+var ErrNotFound = errors.New("not found")
+
+// notFound marks record not found error of resolver with ErrNotFound, other errors are returned as is
+func notFound(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("%w: %w", ErrNotFound, err)
+	}
+
+	return err
+}
@@ -2,6 +2,9 @@ package resolvers
 
 import (
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"strings"
+	"time"
 	"weather-subscriptions/internal/db/models"
 )
 
@@ -9,19 +12,48 @@ type Resolver interface {
 	UserByID(id string) (*models.User, error)
 	UserByEmail(email string) (*models.User, error)
 	Token(token string) (*models.Token, error)
-	SubToken(userID string) (*models.Token, error)
-	UnsubToken(userID string) (*models.Token, error)
-	UserToken(userID, tokenType string) (*models.Token, error)
-	Subscription(userID string) (*models.Subscription, error)
+	SubToken(subscriptionID string) (*models.Token, error)
+	UnsubToken(subscriptionID string) (*models.Token, error)
+	ManageToken(subscriptionID string) (*models.Token, error)
+	Subscription(id string) (*models.Subscription, error)
+	UserSubscription(userID, cityID string) (*models.Subscription, error)
+	UserSubscriptions(userID string) ([]*models.Subscription, error)
 	Subscriptions(subscriptionType models.SubscriptionType) ([]*models.Subscription, error)
+	SubscriptionTimezones(subscriptionType models.SubscriptionType) ([]string, error)
+	SubscriptionsAt(subscriptionType models.SubscriptionType, timezone string, hours []int) ([]*models.Subscription, error)
+	AlertRules(subscriptionID string) ([]models.AlertRule, error)
+	RemoveAlertRules(subscriptionID string) error
 	City(name string) (*models.City, error)
 	CityByID(id string) (*models.City, error)
+	CityByPlaceID(placeID string) (*models.City, error)
+	NearestCity(latitude, longitude, radius float64) (*models.City, error)
+	SearchCities(query string, limit int) ([]*models.City, error)
 	Weather(CityID string) (*models.Weather, error)
-	WeatherByCityID(cityID string) (*models.Weather, error)
+	WeatherByCityID(cityID, language string) (*models.Weather, error)
+	Forecast(cityID, language string) (*models.Forecast, error)
+	RemoveForecasts(cityID, language, exceptID string) error
+	ClaimOutboxMessages(now, leasedUntil time.Time, limit int) ([]*models.OutboxMessage, error)
+	CreateOutboxMessage(message *models.OutboxMessage) (bool, error)
+	ClaimJobRun(run *models.JobRun) (bool, error)
+	RenewJobRun(run *models.JobRun) (bool, error)
+	FinishJobRun(run *models.JobRun) (bool, error)
+	Suppression(email string) (*models.Suppression, error)
+	Suppressions() ([]*models.Suppression, error)
+	LockRateLimit(initial *models.RateLimit) (*models.RateLimit, error)
+	RemoveRateLimits(fullBefore time.Time) error
 	Save(model any) error
 	Remove(model any) error
+	Transaction(fn func(resolver Resolver) error) error
 }
 
+const (
+	// distanceSQL is great-circle distance in kilometers between cities row and latitude, longitude
+	// and latitude arguments
+	distanceSQL = "6371 * acos(least(1, cos(radians(?)) * cos(radians(latitude)) * " +
+		"cos(radians(longitude) - radians(?)) + sin(radians(?)) * sin(radians(latitude))))"
+	kilometersPerDegree = 111.2
+)
+
 type DBResolver struct {
 	db *gorm.DB
 }
@@ -44,32 +76,124 @@ func (r *DBResolver) Token(token string) (t *models.Token, err error) {
 	return t, r.db.First(&t, "token = ?", token).Error
 }
 
-func (r *DBResolver) SubToken(userID string) (token *models.Token, err error) {
-	return token, r.db.First(&token, "user_id = ? AND type = ?", userID, models.Sub).Error
+func (r *DBResolver) SubToken(subscriptionID string) (token *models.Token, err error) {
+	return token, r.db.First(&token, "subscription_id = ? AND type = ?", subscriptionID, models.Sub).Error
+}
+
+func (r *DBResolver) UnsubToken(subscriptionID string) (t *models.Token, err error) {
+	return t, r.db.First(&t, "subscription_id = ? AND type = ?", subscriptionID, models.Unsub).Error
 }
 
-func (r *DBResolver) UnsubToken(userID string) (t *models.Token, err error) {
-	return t, r.db.First(&t, "user_id = ? AND type = ?", userID, models.Unsub).Error
+func (r *DBResolver) ManageToken(subscriptionID string) (t *models.Token, err error) {
+	return t, r.db.First(&t, "subscription_id = ? AND type = ?", subscriptionID, models.Manage).Error
 }
 
-func (r *DBResolver) UserToken(userID, tokenType string) (token *models.Token, err error) {
-	return token, r.db.First(&token, "user_id = ? AND token_type = ?", userID, tokenType).Error
+func (r *DBResolver) Subscription(id string) (subscription *models.Subscription, err error) {
+	return subscription, r.db.First(&subscription, "id = ?", id).Error
 }
 
-func (r *DBResolver) Subscription(userID string) (subscription *models.Subscription, err error) {
-	return subscription, r.db.First(&subscription, "user_id = ?", userID).Error
+func (r *DBResolver) UserSubscription(userID, cityID string) (subscription *models.Subscription, err error) {
+	return subscription, r.db.First(&subscription, "user_id = ? AND city_id = ?", userID, cityID).Error
+}
+
+func (r *DBResolver) UserSubscriptions(userID string) (subscriptions []*models.Subscription, err error) {
+	return subscriptions, r.db.Preload("City").Where("user_id = ?", userID).Find(&subscriptions).Error
 }
 
 func (r *DBResolver) Subscriptions(subscriptionType models.SubscriptionType) (subscriptions []*models.Subscription, err error) {
-	return subscriptions, r.db.Preload("User").Where("frequency = ?", subscriptionType).Find(&subscriptions).Error
+	return subscriptions, r.db.
+		Preload("User").
+		Preload("City").
+		Preload("AlertRules").
+		Scopes(deliverable(subscriptionType)).
+		Find(&subscriptions).
+		Error
+}
+
+func (r *DBResolver) AlertRules(subscriptionID string) (rules []models.AlertRule, err error) {
+	return rules, r.db.Where("subscription_id = ?", subscriptionID).Find(&rules).Error
+}
+
+// deliverable filters confirmed subscriptions of the type which are neither paused nor snoozed
+// and which user email is not suppressed
+func deliverable(subscriptionType models.SubscriptionType) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.
+			Where("frequency = ? AND confirmed = ? AND paused = ?", subscriptionType, true, false).
+			Where("(snoozed_until IS NULL OR snoozed_until <= now())").
+			Where("NOT EXISTS (SELECT 1 FROM users JOIN suppressions ON suppressions.email = lower(users.email) " +
+				"WHERE users.id = subscriptions.user_id)")
+	}
+}
+
+func (r *DBResolver) RemoveAlertRules(subscriptionID string) error {
+	return r.db.Where("subscription_id = ?", subscriptionID).Delete(&models.AlertRule{}).Error
+}
+
+func (r *DBResolver) SubscriptionTimezones(subscriptionType models.SubscriptionType) (timezones []string, err error) {
+	return timezones, r.db.
+		Model(&models.Subscription{}).
+		Distinct("timezone").
+		Scopes(deliverable(subscriptionType)).
+		Pluck("timezone", &timezones).
+		Error
+}
+
+func (r *DBResolver) SubscriptionsAt(
+	subscriptionType models.SubscriptionType,
+	timezone string,
+	hours []int,
+) (subscriptions []*models.Subscription, err error) {
+	return subscriptions, r.db.
+		Preload("User").
+		Preload("City").
+		Scopes(deliverable(subscriptionType)).
+		Where("timezone = ? AND delivery_hour IN ?", timezone, hours).
+		Find(&subscriptions).
+		Error
 }
 
 func (r *DBResolver) CityByID(id string) (city *models.City, err error) {
 	return city, r.db.First(&city, "id = ?", id).Error
 }
 
+// City returns city of the name or of the alias, both are matched case-insensitively
 func (r *DBResolver) City(name string) (city *models.City, err error) {
-	return city, r.db.First(&city, "name ILIKE ?", name).Error
+	aliased := r.db.Model(&models.CityAlias{}).Select("city_id").Where("alias = ?", strings.ToLower(name))
+	return city, r.db.Where("name ILIKE ?", name).Or("id IN (?)", aliased).First(&city).Error
+}
+
+func (r *DBResolver) CityByPlaceID(placeID string) (city *models.City, err error) {
+	return city, r.db.First(&city, "google_place_id = ?", placeID).Error
+}
+
+// NearestCity returns city nearest to the coordinates within radius in kilometers. Cities are
+// prefiltered by latitude, which keeps the same distance in kilometers per degree everywhere
+func (r *DBResolver) NearestCity(latitude, longitude, radius float64) (city *models.City, err error) {
+	distance := clause.Expr{SQL: distanceSQL, Vars: []any{latitude, longitude, latitude}}
+	delta := radius / kilometersPerDegree
+	return city, r.db.
+		Where("latitude BETWEEN ? AND ?", latitude-delta, latitude+delta).
+		Where("? <= ?", distance, radius).
+		Order(clause.OrderBy{Expression: distance}).
+		Take(&city).
+		Error
+}
+
+// SearchCities returns cities whose name or alias starts with the slug query or is similar to it,
+// names starting with the query are the first
+func (r *DBResolver) SearchCities(query string, limit int) (cities []*models.City, err error) {
+	prefix := query + "%"
+	aliased := r.db.Model(&models.CityAlias{}).Select("city_id").Where("alias LIKE ? OR alias % ?", prefix, query)
+	return cities, r.db.
+		Where("name LIKE ? OR name % ? OR id IN (?)", prefix, query, aliased).
+		Order(clause.OrderBy{Expression: clause.Expr{
+			SQL:  "name LIKE ? DESC, similarity(name, ?) DESC, name",
+			Vars: []any{prefix, query},
+		}}).
+		Limit(limit).
+		Find(&cities).
+		Error
 }
 
 func (r *DBResolver) Weather(CityID string) (weather *models.Weather, err error) {
@@ -79,8 +203,125 @@ func (r *DBResolver) Weather(CityID string) (weather *models.Weather, err error)
 		Error
 }
 
-func (r *DBResolver) WeatherByCityID(cityID string) (weather *models.Weather, err error) {
-	return weather, r.db.Order("time desc").First(&weather, "city_id = ?", cityID).Error
+// WeatherByCityID returns the latest weather of the city fetched in the language
+func (r *DBResolver) WeatherByCityID(cityID, language string) (weather *models.Weather, err error) {
+	return weather, r.db.Order("time desc").First(&weather, "city_id = ? AND language = ?", cityID, language).Error
+}
+
+// Forecast returns the latest forecast of the city in the language with days and hours in chronological order
+func (r *DBResolver) Forecast(cityID, language string) (forecast *models.Forecast, err error) {
+	return forecast, r.db.
+		Preload("Days", func(db *gorm.DB) *gorm.DB { return db.Order("date") }).
+		Preload("Hours", func(db *gorm.DB) *gorm.DB { return db.Order("time") }).
+		Order("time DESC").
+		First(&forecast, "city_id = ? AND language = ?", cityID, language).
+		Error
+}
+
+// RemoveForecasts removes forecasts of the city in the language replaced by the one with exceptID
+func (r *DBResolver) RemoveForecasts(cityID, language, exceptID string) error {
+	return r.db.
+		Where("city_id = ? AND language = ? AND id <> ?", cityID, language, exceptID).
+		Delete(&models.Forecast{}).
+		Error
+}
+
+// ClaimOutboxMessages leases due pending messages until leasedUntil and counts their delivery attempt
+// in a single statement, so the rows are locked only while it runs. Rows locked by another claim are
+// skipped and leased ones are not due until the lease expires
+func (r *DBResolver) ClaimOutboxMessages(
+	now, leasedUntil time.Time,
+	limit int,
+) (messages []*models.OutboxMessage, err error) {
+	due := r.db.Model(&models.OutboxMessage{}).
+		Select("id").
+		Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+		Where("status = ? AND next_attempt_at <= ?", models.OutboxPending, now).
+		Order("next_attempt_at").
+		Limit(limit)
+
+	return messages, r.db.Model(&messages).
+		Clauses(clause.Returning{}).
+		Where("id IN (?)", due).
+		Updates(map[string]any{
+			"next_attempt_at": leasedUntil,
+			"attempts":        gorm.Expr("attempts + 1"),
+		}).
+		Error
+}
+
+// CreateOutboxMessage inserts message unless a message with the same dedup key exists, it reports whether
+// the message was inserted
+func (r *DBResolver) CreateOutboxMessage(message *models.OutboxMessage) (bool, error) {
+	result := r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "dedup_key"}},
+		DoNothing: true,
+	}).Create(message)
+	return result.RowsAffected == 1, result.Error
+}
+
+// ClaimJobRun inserts job run or takes over unfinished run of the slot whose lease expired before
+// the run started, it reports whether the run is claimed, false means the slot is taken
+func (r *DBResolver) ClaimJobRun(run *models.JobRun) (bool, error) {
+	result := r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "job"}, {Name: "slot"}},
+		DoUpdates: clause.AssignmentColumns([]string{"runner", "started_at", "leased_until"}),
+		Where: clause.Where{Exprs: []clause.Expression{
+			clause.Expr{
+				SQL:  "job_runs.finished_at IS NULL AND job_runs.leased_until < ?",
+				Vars: []any{run.StartedAt},
+			},
+		}},
+	}).Create(run)
+	return result.RowsAffected == 1, result.Error
+}
+
+// RenewJobRun extends lease of the run, it reports whether the run was renewed, false means another
+// runner took it over
+func (r *DBResolver) RenewJobRun(run *models.JobRun) (bool, error) {
+	result := r.db.Model(&models.JobRun{}).
+		Where("job = ? AND slot = ? AND runner = ?", run.Job, run.Slot, run.Runner).
+		Update("leased_until", run.LeasedUntil)
+	return result.RowsAffected == 1, result.Error
+}
+
+// FinishJobRun records result of the run, it reports whether the run was recorded, false means another
+// runner took it over and the result of the run is left to it
+func (r *DBResolver) FinishJobRun(run *models.JobRun) (bool, error) {
+	result := r.db.Model(&models.JobRun{}).
+		Where("job = ? AND slot = ? AND runner = ?", run.Job, run.Slot, run.Runner).
+		Select("finished_at", "sent", "failed", "skipped", "error").
+		Updates(run)
+	return result.RowsAffected == 1, result.Error
+}
+
+// Suppression returns suppression of the lowercase email
+func (r *DBResolver) Suppression(email string) (suppression *models.Suppression, err error) {
+	return suppression, r.db.First(&suppression, "email = ?", email).Error
+}
+
+// Suppressions returns suppressed addresses, the latest first
+func (r *DBResolver) Suppressions() (suppressions []*models.Suppression, err error) {
+	return suppressions, r.db.Order("updated_at DESC").Find(&suppressions).Error
+}
+
+// LockRateLimit returns rate limit bucket of the initial key locked until the end of transaction,
+// initial bucket is inserted when the key has none
+func (r *DBResolver) LockRateLimit(initial *models.RateLimit) (bucket *models.RateLimit, err error) {
+	err = r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(initial).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return bucket, r.db.
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		First(&bucket, "key = ?", initial.Key).
+		Error
+}
+
+// RemoveRateLimits removes buckets which are full before the time
+func (r *DBResolver) RemoveRateLimits(fullBefore time.Time) error {
+	return r.db.Where("full_at < ?", fullBefore).Delete(&models.RateLimit{}).Error
 }
 
 func (r *DBResolver) Save(model any) error {
@@ -88,3 +329,9 @@ func (r *DBResolver) Save(model any) error {
 }
 
 func (r *DBResolver) Remove(model any) error { return r.db.Delete(model).Error }
+
+func (r *DBResolver) Transaction(fn func(resolver Resolver) error) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return fn(New(tx))
+	})
+}
@@ -2,7 +2,10 @@ package state
 
 import (
 	"gorm.io/gorm"
+	"strconv"
 	"strings"
+	"time"
+	"weather-subscriptions/internal/config"
 	"weather-subscriptions/internal/db/models"
 	"weather-subscriptions/internal/state/resolvers"
 )
@@ -12,120 +15,206 @@ type Stateful interface {
 	GetUserByEmail(email string) (*models.User, error)
 	GetCity(name string) (*models.City, error)
 	GetCityByID(id string) (*models.City, error)
-	GetWeather(cityID string) (*models.Weather, error)
+	GetCityByPlaceID(placeID string) (*models.City, error)
+	GetNearestCity(latitude, longitude, radius float64) (*models.City, error)
+	SearchCities(query string, limit int) ([]*models.City, error)
+	GetCityCandidates(query string) ([]*models.City, error)
+	GetCityCandidate(name string) (*models.City, error)
+	SaveCityCandidates(query string, candidates []*models.City)
+	GetWeather(cityID, language string) (*models.Weather, error)
+	GetForecast(cityID, language string) (*models.Forecast, error)
 	GetToken(tokens string) (*models.Token, error)
-	GetUnsubToken(userID string) (*models.Token, error)
-	GetSubToken(userID string) (*models.Token, error)
-	GetSubscription(userID string) (*models.Subscription, error)
+	GetUnsubToken(subscriptionID string) (*models.Token, error)
+	GetSubToken(subscriptionID string) (*models.Token, error)
+	GetManageToken(subscriptionID string) (*models.Token, error)
+	GetSubscription(id string) (*models.Subscription, error)
+	GetUserSubscription(userID, cityID string) (*models.Subscription, error)
+	GetUserSubscriptions(userID string) ([]*models.Subscription, error)
 	GetSubscriptions(subscriptionType models.SubscriptionType) ([]*models.Subscription, error)
+	GetSubscriptionTimezones(subscriptionType models.SubscriptionType) ([]string, error)
+	GetSubscriptionsAt(subscriptionType models.SubscriptionType, timezone string, hours []int) ([]*models.Subscription, error)
+	GetAlertRules(subscriptionID string) ([]models.AlertRule, error)
 	SaveWeather(weather *models.Weather) error
+	SaveForecast(forecast *models.Forecast) error
 	SaveCity(city *models.City) error
+	SaveCityAlias(alias *models.CityAlias) error
 	SaveUser(user *models.User) error
 	SaveToken(token *models.Token) error
 	SaveSubscription(subscription *models.Subscription) error
+	SaveAlertRule(rule *models.AlertRule) error
+	ClaimOutboxMessages(now, leasedUntil time.Time, limit int) ([]*models.OutboxMessage, error)
+	SaveOutboxMessage(message *models.OutboxMessage) error
+	CreateOutboxMessage(message *models.OutboxMessage) (bool, error)
+	ClaimJobRun(run *models.JobRun) (bool, error)
+	RenewJobRun(run *models.JobRun) (bool, error)
+	FinishJobRun(run *models.JobRun) (bool, error)
+	GetSuppression(email string) (*models.Suppression, error)
+	GetSuppressions() ([]*models.Suppression, error)
+	SaveSuppression(suppression *models.Suppression) error
+	RemoveSuppression(suppression *models.Suppression) error
+	LockRateLimit(initial *models.RateLimit) (*models.RateLimit, error)
+	SaveRateLimit(bucket *models.RateLimit) error
+	RemoveRateLimits(fullBefore time.Time) error
+	RemoveAlertRules(subscriptionID string) error
 	RemoveSubscription(subscription *models.Subscription) error
 	RemoveToken(token *models.Token) error
 	RemoveUser(user *models.User) error
+	Transaction(fn func(state Stateful) error) error
+	CacheStats() map[string]CacheStats
 }
 
 type State struct {
+	cfg           *config.Config
 	resolver      resolvers.Resolver
-	user          map[string]*models.User
-	cities        map[string]*models.City
-	cityIDMap     map[string]*models.City
-	weather       map[string]*models.Weather
-	tokens        map[string]*models.Token
-	subscriptions map[string]*models.Subscription
+	user          *cache[*models.User]
+	cities        *cache[*models.City]
+	cityIDMap     *cache[*models.City]
+	weather       *cache[*models.Weather]
+	forecasts     *cache[*models.Forecast]
+	tokens        *cache[*models.Token]
+	subscriptions *cache[*models.Subscription]
+	searches      *cache[[]*models.City]
+	// candidates are geocoded places of search queries, they are kept in memory only until one is selected
+	candidates     *cache[[]*models.City]
+	candidateNames *cache[*models.City]
+	// evictions are set for transaction state only
+	evictions []func(st *State)
 }
 
 func (s *State) GetUser(id string) (*models.User, error) {
-	user, ok := s.user[id]
+	user, ok := s.user.Get(id)
 	if !ok {
 		foundUser, err := s.resolver.UserByID(id)
 		if err != nil {
-			return nil, err
+			return nil, notFound(err)
 		}
 		user = foundUser
+		s.user.Set(id, user)
 	}
-	s.user[id] = user
 
 	return user, nil
 }
 
 func (s *State) GetUserByEmail(email string) (*models.User, error) {
-	user, ok := s.user[email]
+	user, ok := s.user.Get(email)
 	if !ok {
 		foundUser, err := s.resolver.UserByEmail(email)
 		if err != nil {
-			return nil, err
+			return nil, notFound(err)
 		}
 		user = foundUser
+		s.user.Set(email, user)
 	}
 
-	s.user[email] = user
 	return user, nil
 }
 
-func (s *State) GetWeather(cityID string) (*models.Weather, error) {
-	weather, ok := s.weather[cityID]
+// GetWeather returns the latest weather of the city fetched in the language
+func (s *State) GetWeather(cityID, language string) (*models.Weather, error) {
+	key := languageKey(cityID, language)
+	weather, ok := s.weather.Get(key)
 	if !ok {
-		foundWeather, err := s.resolver.WeatherByCityID(cityID)
+		foundWeather, err := s.resolver.WeatherByCityID(cityID, language)
 		if err != nil {
-			return nil, err
+			return nil, notFound(err)
 		}
 		weather = foundWeather
+		s.weather.Set(key, weather)
 	}
-	s.weather[cityID] = weather
 
 	return weather, nil
 }
 
+// GetForecast returns the latest forecast of the city fetched in the language
+func (s *State) GetForecast(cityID, language string) (*models.Forecast, error) {
+	key := languageKey(cityID, language)
+	forecast, ok := s.forecasts.Get(key)
+	if !ok {
+		foundForecast, err := s.resolver.Forecast(cityID, language)
+		if err != nil {
+			return nil, notFound(err)
+		}
+		forecast = foundForecast
+		s.forecasts.Set(key, forecast)
+	}
+
+	return forecast, nil
+}
+
 func (s *State) GetToken(token string) (*models.Token, error) {
-	userToken, ok := s.tokens[token]
+	userToken, ok := s.tokens.Get(token)
 	if !ok {
 		foundToken, err := s.resolver.Token(token)
 		if err != nil {
-			return nil, err
+			return nil, notFound(err)
 		}
 		userToken = foundToken
+		s.tokens.Set(token, userToken)
 	}
-	s.tokens[token] = userToken
 
 	return userToken, nil
 }
 
-func (s *State) GetSubToken(userID string) (*models.Token, error) {
-	token, err := s.resolver.SubToken(userID)
+func (s *State) GetSubToken(subscriptionID string) (*models.Token, error) {
+	token, err := s.resolver.SubToken(subscriptionID)
 	if err != nil {
-		return nil, err
+		return nil, notFound(err)
 	}
 
 	return token, nil
 }
 
-func (s *State) GetUnsubToken(userID string) (*models.Token, error) {
-	token, err := s.resolver.UnsubToken(userID)
+func (s *State) GetUnsubToken(subscriptionID string) (*models.Token, error) {
+	token, err := s.resolver.UnsubToken(subscriptionID)
 	if err != nil {
-		return nil, err
+		return nil, notFound(err)
+	}
+
+	return token, nil
+}
+
+func (s *State) GetManageToken(subscriptionID string) (*models.Token, error) {
+	token, err := s.resolver.ManageToken(subscriptionID)
+	if err != nil {
+		return nil, notFound(err)
 	}
 
 	return token, nil
 }
 
-func (s *State) GetSubscription(userID string) (*models.Subscription, error) {
-	subscription, ok := s.subscriptions[userID]
+func (s *State) GetSubscription(id string) (*models.Subscription, error) {
+	subscription, ok := s.subscriptions.Get(id)
 	if !ok {
-		foundSubscription, err := s.resolver.Subscription(userID)
+		foundSubscription, err := s.resolver.Subscription(id)
 		if err != nil {
-			return nil, err
+			return nil, notFound(err)
 		}
 		subscription = foundSubscription
+		s.subscriptions.Set(id, subscription)
 	}
-	s.subscriptions[userID] = subscription
 
 	return subscription, nil
 }
 
+func (s *State) GetUserSubscription(userID, cityID string) (*models.Subscription, error) {
+	subscription, err := s.resolver.UserSubscription(userID, cityID)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	s.subscriptions.Set(subscription.ID, subscription)
+
+	return subscription, nil
+}
+
+func (s *State) GetUserSubscriptions(userID string) ([]*models.Subscription, error) {
+	subscriptions, err := s.resolver.UserSubscriptions(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return subscriptions, nil
+}
+
 func (s *State) GetSubscriptions(subscriptionType models.SubscriptionType) (subs []*models.Subscription, err error) {
 	subscriptions, err := s.resolver.Subscriptions(subscriptionType)
 	if err != nil {
@@ -135,63 +224,186 @@ func (s *State) GetSubscriptions(subscriptionType models.SubscriptionType) (subs
 	return subscriptions, nil
 }
 
-func (s *State) GetCity(name string) (*models.City, error) {
-	city, ok := s.cities[strings.ToLower(name)]
+func (s *State) GetSubscriptionTimezones(subscriptionType models.SubscriptionType) ([]string, error) {
+	timezones, err := s.resolver.SubscriptionTimezones(subscriptionType)
+	if err != nil {
+		return nil, err
+	}
+
+	return timezones, nil
+}
+
+func (s *State) GetSubscriptionsAt(
+	subscriptionType models.SubscriptionType,
+	timezone string,
+	hours []int,
+) ([]*models.Subscription, error) {
+	subscriptions, err := s.resolver.SubscriptionsAt(subscriptionType, timezone, hours)
+	if err != nil {
+		return nil, err
+	}
+
+	return subscriptions, nil
+}
+
+func (s *State) GetAlertRules(subscriptionID string) ([]models.AlertRule, error) {
+	rules, err := s.resolver.AlertRules(subscriptionID)
+	if err != nil {
+		return nil, err
+	}
 
+	return rules, nil
+}
+
+func (s *State) GetCity(name string) (*models.City, error) {
+	city, ok := s.cities.Get(strings.ToLower(name))
 	if !ok {
 		foundCity, err := s.resolver.City(name)
 		if err != nil {
-			return nil, err
+			return nil, notFound(err)
 		}
-
 		city = foundCity
+		s.cities.Set(strings.ToLower(name), city)
 	}
-	s.cities[name] = city
 
 	return city, nil
 }
 
-// todo: remove
 func (s *State) GetCityByID(id string) (*models.City, error) {
-	city, ok := s.cityIDMap[id]
+	city, ok := s.cityIDMap.Get(id)
 	if !ok {
 		foundCity, err := s.resolver.CityByID(id)
 		if err != nil {
-			return nil, err
+			return nil, notFound(err)
 		}
 		city = foundCity
+		s.cityIDMap.Set(id, city)
+	}
+
+	return city, nil
+}
+
+// GetCityByPlaceID returns city of the geocoding provider place, it is not cached
+func (s *State) GetCityByPlaceID(placeID string) (*models.City, error) {
+	city, err := s.resolver.CityByPlaceID(placeID)
+	if err != nil {
+		return nil, notFound(err)
+	}
+
+	return city, nil
+}
+
+// GetNearestCity returns city nearest to the coordinates within radius in kilometers, it is not cached
+func (s *State) GetNearestCity(latitude, longitude, radius float64) (*models.City, error) {
+	city, err := s.resolver.NearestCity(latitude, longitude, radius)
+	if err != nil {
+		return nil, notFound(err)
 	}
 
 	return city, nil
 }
 
+// SearchCities returns known cities matching the slug query, empty results are not cached
+func (s *State) SearchCities(query string, limit int) ([]*models.City, error) {
+	key := query + ":" + strconv.Itoa(limit)
+	cities, ok := s.searches.Get(key)
+	if !ok {
+		foundCities, err := s.resolver.SearchCities(query, limit)
+		if err != nil {
+			return nil, err
+		}
+		cities = foundCities
+		if len(cities) > 0 {
+			s.searches.Set(key, cities)
+		}
+	}
+
+	return cities, nil
+}
+
+// GetCityCandidates returns geocoded places of the slug query, which are not saved unless selected
+func (s *State) GetCityCandidates(query string) ([]*models.City, error) {
+	candidates, ok := s.candidates.Get(query)
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	return candidates, nil
+}
+
+// GetCityCandidate returns geocoded place of a search by its name
+func (s *State) GetCityCandidate(name string) (*models.City, error) {
+	candidate, ok := s.candidateNames.Get(name)
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	return candidate, nil
+}
+
+// SaveCityCandidates keeps geocoded places of the slug query in memory for CACHE_SEARCH_TTL, so they are
+// neither geocoded again nor saved to the database before one of them is selected
+func (s *State) SaveCityCandidates(query string, candidates []*models.City) {
+	s.candidates.Set(query, candidates)
+	for _, candidate := range candidates {
+		s.candidateNames.Set(candidate.Name, candidate)
+	}
+}
+
 func (s *State) SaveWeather(weather *models.Weather) error {
 	err := s.resolver.Save(weather)
 	if err != nil {
 		return err
 	}
-	s.weather[weather.CityID] = weather
+	s.weather.Set(languageKey(weather.CityID, weather.Language), weather)
+
+	return nil
+}
+
+// SaveForecast saves the forecast and removes forecasts of the city in the same language it replaces
+func (s *State) SaveForecast(forecast *models.Forecast) error {
+	err := s.resolver.Save(forecast)
+	if err != nil {
+		return err
+	}
+	err = s.resolver.RemoveForecasts(forecast.CityID, forecast.Language, forecast.ID)
+	if err != nil {
+		return err
+	}
+	s.forecasts.Set(languageKey(forecast.CityID, forecast.Language), forecast)
 
 	return nil
 }
 
+// languageKey is a cache key of city data fetched in the language
+func languageKey(cityID, language string) string {
+	return cityID + ":" + language
+}
+
 func (s *State) SaveCity(city *models.City) error {
 	err := s.resolver.Save(city)
 	if err != nil {
 		return err
 	}
-	s.cities[strings.ToLower(city.Name)] = city
+	s.cities.Set(strings.ToLower(city.Name), city)
+	s.cityIDMap.Set(city.ID, city)
 
 	return nil
 }
 
+// SaveCityAlias saves the spelling of the city, it is found by GetCity since then
+func (s *State) SaveCityAlias(alias *models.CityAlias) error {
+	alias.Alias = strings.ToLower(alias.Alias)
+	return s.resolver.Save(alias)
+}
+
 func (s *State) SaveUser(user *models.User) error {
 	err := s.resolver.Save(user)
 	if err != nil {
 		return err
 	}
-	s.user[user.Email] = user
-	s.user[user.ID] = user
+	s.user.Set(user.Email, user)
+	s.user.Set(user.ID, user)
 
 	return nil
 }
@@ -201,7 +413,7 @@ func (s *State) SaveToken(token *models.Token) error {
 	if err != nil {
 		return err
 	}
-	s.tokens[token.Token] = token
+	s.tokens.Set(token.Token, token)
 
 	return nil
 }
@@ -211,19 +423,97 @@ func (s *State) SaveSubscription(subscription *models.Subscription) error {
 	if err != nil {
 		return err
 	}
-	s.subscriptions[subscription.UserID] = subscription
+	s.subscriptions.Set(subscription.ID, subscription)
 
 	return nil
 }
 
+func (s *State) SaveAlertRule(rule *models.AlertRule) error {
+	return s.resolver.Save(rule)
+}
+
+// ClaimOutboxMessages leases due messages to the caller until leasedUntil, see resolvers.DBResolver
+func (s *State) ClaimOutboxMessages(now, leasedUntil time.Time, limit int) ([]*models.OutboxMessage, error) {
+	messages, err := s.resolver.ClaimOutboxMessages(now, leasedUntil, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return messages, nil
+}
+
+func (s *State) SaveOutboxMessage(message *models.OutboxMessage) error {
+	return s.resolver.Save(message)
+}
+
+func (s *State) CreateOutboxMessage(message *models.OutboxMessage) (bool, error) {
+	return s.resolver.CreateOutboxMessage(message)
+}
+
+func (s *State) ClaimJobRun(run *models.JobRun) (bool, error) {
+	return s.resolver.ClaimJobRun(run)
+}
+
+func (s *State) RenewJobRun(run *models.JobRun) (bool, error) {
+	return s.resolver.RenewJobRun(run)
+}
+
+func (s *State) FinishJobRun(run *models.JobRun) (bool, error) {
+	return s.resolver.FinishJobRun(run)
+}
+
+// GetSuppression returns suppression of the lowercase email, suppressions are not cached
+// so that a bounce stops delivery on every replica at once
+func (s *State) GetSuppression(email string) (*models.Suppression, error) {
+	suppression, err := s.resolver.Suppression(email)
+	if err != nil {
+		return nil, notFound(err)
+	}
+
+	return suppression, nil
+}
+
+func (s *State) GetSuppressions() ([]*models.Suppression, error) {
+	return s.resolver.Suppressions()
+}
+
+func (s *State) SaveSuppression(suppression *models.Suppression) error {
+	return s.resolver.Save(suppression)
+}
+
+func (s *State) RemoveSuppression(suppression *models.Suppression) error {
+	return s.resolver.Remove(suppression)
+}
+
+// LockRateLimit returns rate limit bucket locked by the transaction of the state, see Transaction
+func (s *State) LockRateLimit(initial *models.RateLimit) (*models.RateLimit, error) {
+	return s.resolver.LockRateLimit(initial)
+}
+
+func (s *State) SaveRateLimit(bucket *models.RateLimit) error {
+	return s.resolver.Save(bucket)
+}
+
+func (s *State) RemoveRateLimits(fullBefore time.Time) error {
+	return s.resolver.RemoveRateLimits(fullBefore)
+}
+
+func (s *State) RemoveAlertRules(subscriptionID string) error {
+	return s.resolver.RemoveAlertRules(subscriptionID)
+}
+
 func (s *State) RemoveSubscription(subscription *models.Subscription) error {
 	err := s.resolver.Remove(subscription)
 	if err != nil {
 		return err
 	}
 
-	delete(s.subscriptions, subscription.UserID)
-	delete(s.user, subscription.UserID)
+	s.evict(func(st *State) {
+		st.subscriptions.Delete(subscription.ID)
+		st.tokens.DeleteFunc(func(_ string, token *models.Token) bool {
+			return token.SubscriptionID == subscription.ID
+		})
+	})
 
 	return nil
 }
@@ -234,8 +524,14 @@ func (s *State) RemoveUser(user *models.User) error {
 		return err
 	}
 
-	delete(s.user, user.ID)
-	delete(s.subscriptions, user.ID)
+	s.evict(func(st *State) {
+		st.user.DeleteFunc(func(_ string, cached *models.User) bool {
+			return cached.ID == user.ID
+		})
+		st.subscriptions.DeleteFunc(func(_ string, subscription *models.Subscription) bool {
+			return subscription.UserID == user.ID
+		})
+	})
 
 	return nil
 }
@@ -246,20 +542,88 @@ func (s *State) RemoveToken(token *models.Token) error {
 		return err
 	}
 
-	delete(s.tokens, token.Token)
+	s.evict(func(st *State) {
+		st.tokens.Delete(token.Token)
+	})
+
+	return nil
+}
+
+// Transaction runs fn with state bound to a database transaction, which is committed if fn returns nil.
+// Cached entries are propagated to this state only after commit
+func (s *State) Transaction(fn func(state Stateful) error) error {
+	var txState *State
+	err := s.resolver.Transaction(func(resolver resolvers.Resolver) error {
+		txState = newState(s.cfg, resolver)
+		txState.evictions = []func(st *State){}
+		return fn(txState)
+	})
+	if err != nil {
+		return err
+	}
+	s.merge(txState)
 
 	return nil
 }
 
-func NewState(db *gorm.DB) Stateful {
-	resolver := resolvers.New(db)
+// CacheStats returns hit, miss and size counters of every entity cache
+func (s *State) CacheStats() map[string]CacheStats {
+	return map[string]CacheStats{
+		"users":          s.user.Stats(),
+		"cities":         s.cities.Stats(),
+		"citiesByID":     s.cityIDMap.Stats(),
+		"weather":        s.weather.Stats(),
+		"forecasts":      s.forecasts.Stats(),
+		"tokens":         s.tokens.Stats(),
+		"subscriptions":  s.subscriptions.Stats(),
+		"searches":       s.searches.Stats(),
+		"candidates":     s.candidates.Stats(),
+		"candidateNames": s.candidateNames.Stats(),
+	}
+}
+
+// evict removes cached entries, inside transaction the removal is repeated on parent state after commit
+func (s *State) evict(fn func(st *State)) {
+	fn(s)
+	if s.evictions != nil {
+		s.evictions = append(s.evictions, fn)
+	}
+}
+
+func (s *State) merge(txState *State) {
+	for _, fn := range txState.evictions {
+		s.evict(fn)
+	}
+	txState.user.CopyTo(s.user)
+	txState.cities.CopyTo(s.cities)
+	txState.cityIDMap.CopyTo(s.cityIDMap)
+	txState.weather.CopyTo(s.weather)
+	txState.forecasts.CopyTo(s.forecasts)
+	txState.tokens.CopyTo(s.tokens)
+	txState.subscriptions.CopyTo(s.subscriptions)
+	txState.searches.CopyTo(s.searches)
+	txState.candidates.CopyTo(s.candidates)
+	txState.candidateNames.CopyTo(s.candidateNames)
+}
+
+func NewState(cfg *config.Config, db *gorm.DB) Stateful {
+	return newState(cfg, resolvers.New(db))
+}
+
+func newState(cfg *config.Config, resolver resolvers.Resolver) *State {
+	size := cfg.Cache.Size
 	return &State{
-		resolver:      resolver,
-		user:          make(map[string]*models.User),
-		cities:        make(map[string]*models.City),
-		cityIDMap:     make(map[string]*models.City),
-		weather:       make(map[string]*models.Weather),
-		tokens:        make(map[string]*models.Token),
-		subscriptions: make(map[string]*models.Subscription),
+		cfg:            cfg,
+		resolver:       resolver,
+		user:           newCache[*models.User](cfg.Cache.UserTTL, size),
+		cities:         newCache[*models.City](cfg.Cache.CityTTL, size),
+		cityIDMap:      newCache[*models.City](cfg.Cache.CityTTL, size),
+		weather:        newCache[*models.Weather](cfg.Cache.WeatherTTL, size),
+		forecasts:      newCache[*models.Forecast](cfg.Cache.ForecastTTL, size),
+		tokens:         newCache[*models.Token](cfg.Cache.TokenTTL, size),
+		subscriptions:  newCache[*models.Subscription](cfg.Cache.SubscriptionTTL, size),
+		searches:       newCache[[]*models.City](cfg.Cache.SearchTTL, size),
+		candidates:     newCache[[]*models.City](cfg.Cache.SearchTTL, size),
+		candidateNames: newCache[*models.City](cfg.Cache.SearchTTL, size),
 	}
 }
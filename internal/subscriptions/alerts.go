@@ -0,0 +1,65 @@
+package subscriptions
+
+import (
+	"github.com/google/uuid"
+	"strconv"
+	"weather-subscriptions/internal/db/models"
+	"weather-subscriptions/internal/state"
+)
+
+// AlertRuleRequest describes a weather condition alert subscription is notified about
+type AlertRuleRequest struct {
+	Metric   string `validate:"required,oneof=temperature humidity description" json:"metric" form:"metric"`
+	Operator string `validate:"required,oneof=lt gt contains" json:"operator" form:"operator"`
+	Value    string `validate:"required" json:"value" form:"value"`
+}
+
+// newAlertRules checks requested rules and converts them to models
+func newAlertRules(requests []AlertRuleRequest) ([]*models.AlertRule, error) {
+	if len(requests) == 0 {
+		return nil, ErrNoAlertRules
+	}
+
+	rules := make([]*models.AlertRule, 0, len(requests))
+	for _, request := range requests {
+		operator := models.AlertOperator(request.Operator)
+		if models.AlertMetric(request.Metric) == models.DescriptionMetric {
+			if operator != models.Contains {
+				return nil, ErrInvalidAlertRule
+			}
+		} else {
+			if operator == models.Contains {
+				return nil, ErrInvalidAlertRule
+			}
+			if _, err := strconv.ParseFloat(request.Value, 64); err != nil {
+				return nil, ErrInvalidAlertRule
+			}
+		}
+
+		rules = append(rules, &models.AlertRule{
+			ID:       uuid.Must(uuid.NewV7()).String(),
+			Metric:   request.Metric,
+			Operator: request.Operator,
+			Value:    request.Value,
+		})
+	}
+
+	return rules, nil
+}
+
+// saveAlertRules replaces alert rules of the subscription with given ones
+func saveAlertRules(tx state.Stateful, subscriptionID string, rules []*models.AlertRule) error {
+	err := tx.RemoveAlertRules(subscriptionID)
+	if err != nil {
+		return err
+	}
+	for _, rule := range rules {
+		rule.SubscriptionID = subscriptionID
+		err = tx.SaveAlertRule(rule)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,15 @@
+package subscriptions
+
+import "errors"
+
+// Errors returned by SubManager for invalid requests, other errors are internal failures
+var (
+	ErrInvalidToken        = errors.New("invalid token")
+	ErrSubscriptionExists  = errors.New("subscription already exists")
+	ErrUnsupportedLanguage = errors.New("unsupported language")
+	ErrInvalidTimezone     = errors.New("invalid timezone")
+	ErrInvalidSnooze       = errors.New("invalid snooze duration")
+	ErrNoAlertRules        = errors.New("alert subscription requires at least one rule")
+	ErrInvalidAlertRule    = errors.New("invalid alert rule")
+	ErrEmailSuppressed     = errors.New("email is suppressed")
+)
@@ -0,0 +1,273 @@
+package subscriptions
+
+import (
+	"context"
+	"errors"
+	"go.uber.org/zap"
+	"time"
+	"weather-subscriptions/internal/db/models"
+	"weather-subscriptions/internal/state"
+	"weather-subscriptions/internal/templates"
+)
+
+// SubscriptionView is a subscription as it is shown to its subscriber
+type SubscriptionView struct {
+	Email        string             `json:"email"`
+	City         string             `json:"city"`
+	Frequency    string             `json:"frequency"`
+	Timezone     string             `json:"timezone"`
+	DeliveryHour int                `json:"deliveryHour"`
+	Confirmed    bool               `json:"confirmed"`
+	Paused       bool               `json:"paused"`
+	SnoozedUntil *time.Time         `json:"snoozedUntil,omitempty"`
+	Units        string             `json:"units"`
+	Language     string             `json:"language"`
+	Rules        []AlertRuleRequest `json:"rules,omitempty"`
+}
+
+// UpdateRequest changes subscription preferences, empty fields are left unchanged
+type UpdateRequest struct {
+	City         string `validate:"omitempty,city" json:"city" form:"city"`
+	Frequency    string `validate:"omitempty,frequency" json:"frequency" form:"frequency"`
+	Timezone     string `json:"timezone" form:"timezone"`
+	DeliveryHour *int   `validate:"omitempty,min=0,max=23" json:"deliveryHour" form:"deliveryHour"`
+	// Units and Language change preferences of the user, they apply to all user subscriptions
+	Units    string `validate:"omitempty,oneof=metric imperial" json:"units" form:"units"`
+	Language string `json:"language" form:"language"`
+	// Rules replace alert rules, they are required when frequency is changed to "alert"
+	Rules []AlertRuleRequest `validate:"omitempty,dive" json:"rules" form:"rules"`
+}
+
+// ManagedSubscription returns subscription the manage token was issued for
+func (s *SubscriptionManager) ManagedSubscription(token string) (*SubscriptionView, error) {
+	subscription, err := s.managedSubscription(token)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.view(subscription)
+}
+
+// UpdateSubscription changes city, frequency or delivery time of the subscription the manage token was issued for,
+// units and language are changed for the user
+func (s *SubscriptionManager) UpdateSubscription(
+	ctx context.Context,
+	token string,
+	request UpdateRequest,
+) (*SubscriptionView, error) {
+	if request.Language != "" && !templates.SupportedLanguage(request.Language) {
+		return nil, ErrUnsupportedLanguage
+	}
+
+	subscription, err := s.managedSubscription(token)
+	if err != nil {
+		return nil, err
+	}
+	// cached subscription is not touched until the update is committed
+	updated := *subscription
+
+	if request.Frequency != "" {
+		updated.Frequency = request.Frequency
+	}
+	var rules []*models.AlertRule
+	if models.SubscriptionType(updated.Frequency) == models.ALERT &&
+		(len(request.Rules) > 0 || models.SubscriptionType(subscription.Frequency) != models.ALERT) {
+		rules, err = newAlertRules(request.Rules)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var city *models.City
+	if request.City != "" {
+		city, err = s.cities.Find(ctx, request.City)
+		if err != nil {
+			return nil, err
+		}
+		updated.CityID = city.ID
+	}
+	// subscription moved to another city is delivered in its timezone unless other is requested
+	if request.Timezone != "" || updated.CityID != subscription.CityID {
+		updated.Timezone, err = s.subscriptionTimezone(ctx, city, request.Timezone)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if request.DeliveryHour != nil {
+		updated.DeliveryHour = *request.DeliveryHour
+	}
+
+	err = s.state.Transaction(func(tx state.Stateful) error {
+		if request.Units != "" || request.Language != "" {
+			err := updatePreferences(tx, updated.UserID, request)
+			if err != nil {
+				return err
+			}
+		}
+		if updated.CityID != subscription.CityID {
+			existing, err := tx.GetUserSubscription(updated.UserID, updated.CityID)
+			if err != nil && !errors.Is(err, state.ErrNotFound) {
+				return err
+			}
+			if existing != nil {
+				return ErrSubscriptionExists
+			}
+		}
+
+		err := tx.SaveSubscription(&updated)
+		if err != nil {
+			zap.L().Error("error saving subscription", zap.Error(err))
+			return err
+		}
+		if rules != nil {
+			return saveAlertRules(tx, updated.ID, rules)
+		}
+		if models.SubscriptionType(updated.Frequency) != models.ALERT {
+			return tx.RemoveAlertRules(updated.ID)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.view(&updated)
+}
+
+// updatePreferences saves units and language of the request to the user
+func updatePreferences(tx state.Stateful, userID string, request UpdateRequest) error {
+	user, err := tx.GetUser(userID)
+	if err != nil {
+		return err
+	}
+
+	// cached user is not touched until the update is committed
+	updated := *user
+	if request.Units != "" {
+		updated.Units = request.Units
+	}
+	if request.Language != "" {
+		updated.Language = request.Language
+	}
+	err = tx.SaveUser(&updated)
+	if err != nil {
+		zap.L().Error("error saving user", zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+// PauseSubscription stops deliveries of the subscription keeping its preferences
+func (s *SubscriptionManager) PauseSubscription(token string) (*SubscriptionView, error) {
+	return s.updateDelivery(token, func(subscription *models.Subscription) {
+		subscription.Paused = true
+	})
+}
+
+// ResumeSubscription restarts deliveries of the paused or snoozed subscription
+func (s *SubscriptionManager) ResumeSubscription(token string) (*SubscriptionView, error) {
+	return s.updateDelivery(token, func(subscription *models.Subscription) {
+		subscription.Paused = false
+		subscription.SnoozedUntil = nil
+	})
+}
+
+// SnoozeSubscription stops deliveries for given number of days, after that the subscription
+// is delivered again without any action
+func (s *SubscriptionManager) SnoozeSubscription(token string, days int) (*SubscriptionView, error) {
+	if days < 1 || days > maxSnoozeDays {
+		return nil, ErrInvalidSnooze
+	}
+
+	until := time.Now().AddDate(0, 0, days)
+	return s.updateDelivery(token, func(subscription *models.Subscription) {
+		subscription.SnoozedUntil = &until
+	})
+}
+
+// DeleteSubscription removes the subscription the manage token was issued for, other subscriptions
+// of the user are kept
+func (s *SubscriptionManager) DeleteSubscription(token string) error {
+	subscription, err := s.managedSubscription(token)
+	if err != nil {
+		return err
+	}
+
+	return s.removeSubscription(subscription)
+}
+
+// updateDelivery applies update to a copy of the managed subscription and saves it
+func (s *SubscriptionManager) updateDelivery(token string, update func(subscription *models.Subscription)) (*SubscriptionView, error) {
+	subscription, err := s.managedSubscription(token)
+	if err != nil {
+		return nil, err
+	}
+
+	updated := *subscription
+	update(&updated)
+	err = s.state.SaveSubscription(&updated)
+	if err != nil {
+		zap.L().Error("error saving subscription", zap.Error(err))
+		return nil, err
+	}
+
+	return s.view(&updated)
+}
+
+func (s *SubscriptionManager) managedSubscription(token string) (*models.Subscription, error) {
+	userToken, err := s.verifyManageToken(token)
+	if err != nil {
+		return nil, err
+	}
+
+	subscription, err := s.state.GetSubscription(userToken.SubscriptionID)
+	if err != nil {
+		zap.L().Error("error getting subscription", zap.Error(err))
+		return nil, err
+	}
+
+	return subscription, nil
+}
+
+func (s *SubscriptionManager) view(subscription *models.Subscription) (*SubscriptionView, error) {
+	user, err := s.state.GetUser(subscription.UserID)
+	if err != nil {
+		return nil, err
+	}
+	city, err := s.state.GetCityByID(subscription.CityID)
+	if err != nil {
+		return nil, err
+	}
+
+	view := &SubscriptionView{
+		Email:        user.Email,
+		City:         city.Name,
+		Frequency:    subscription.Frequency,
+		Timezone:     subscription.Timezone,
+		DeliveryHour: subscription.DeliveryHour,
+		Confirmed:    subscription.Confirmed,
+		Paused:       subscription.Paused,
+		Units:        user.Units,
+		Language:     user.Language,
+	}
+	if subscription.SnoozedUntil != nil && subscription.SnoozedUntil.After(time.Now()) {
+		view.SnoozedUntil = subscription.SnoozedUntil
+	}
+	if models.SubscriptionType(subscription.Frequency) == models.ALERT {
+		rules, err := s.state.GetAlertRules(subscription.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, rule := range rules {
+			view.Rules = append(view.Rules, AlertRuleRequest{
+				Metric:   rule.Metric,
+				Operator: rule.Operator,
+				Value:    rule.Value,
+			})
+		}
+	}
+
+	return view, nil
+}
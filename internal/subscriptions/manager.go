@@ -3,132 +3,277 @@ package subscriptions
 import (
 	"context"
 	"errors"
-	"fmt"
 	"github.com/google/uuid"
-	"github.com/gosimple/slug"
 	"go.uber.org/zap"
-	"gorm.io/gorm"
+	"time"
+	"weather-subscriptions/internal/cities"
 	"weather-subscriptions/internal/config"
 	"weather-subscriptions/internal/db/models"
 	"weather-subscriptions/internal/integrations"
-	mailer2 "weather-subscriptions/internal/mail/mailer_service"
+	mailer "weather-subscriptions/internal/mail/mailer_service"
+	"weather-subscriptions/internal/mail/outbox"
 	"weather-subscriptions/internal/state"
+	"weather-subscriptions/internal/suppressions"
 	"weather-subscriptions/internal/templates"
 )
 
-const emailValidationCodeLength = 6
+const (
+	emailValidationCodeLength = 6
+	defaultDeliveryHour       = 12
+	defaultTimezone           = "UTC"
+	maxSnoozeDays             = 365
+)
 
 type SubManager interface {
 	InviteUser(ctx context.Context, request SubscribeRequest) error
 	Subscribe(token string) error
 	Unsubscribe(token string) error
+	ManagedSubscription(token string) (*SubscriptionView, error)
+	UpdateSubscription(ctx context.Context, token string, request UpdateRequest) (*SubscriptionView, error)
+	PauseSubscription(token string) (*SubscriptionView, error)
+	ResumeSubscription(token string) (*SubscriptionView, error)
+	SnoozeSubscription(token string, days int) (*SubscriptionView, error)
+	DeleteSubscription(token string) error
 }
 
 type SubscribeRequest struct {
-	Email     string `validate:"required,email" json:"email" form:"email"`
-	City      string `validate:"required" json:"city" form:"city"`
-	Frequency string `validate:"required" json:"frequency" form:"frequency"`
+	Email        string `validate:"required,email" json:"email" form:"email"`
+	City         string `validate:"required_without=Lat,omitempty,city" json:"city" form:"city"`
+	Frequency    string `validate:"required,frequency" json:"frequency" form:"frequency"`
+	Timezone     string `json:"timezone" form:"timezone"`
+	DeliveryHour *int   `validate:"omitempty,min=0,max=23" json:"deliveryHour" form:"deliveryHour"`
+	// Lat and Lon are coordinates of the city, they take precedence over City
+	Lat *float64 `validate:"required_with=Lon,lat" json:"lat" form:"lat"`
+	Lon *float64 `validate:"required_with=Lat,lon" json:"lon" form:"lon"`
+	// Units and Language are preferences of a new user, existing user changes them on the management page
+	Units    string `validate:"omitempty,oneof=metric imperial" json:"units" form:"units"`
+	Language string `json:"language" form:"language"`
+	// Rules are required for "alert" subscriptions and ignored otherwise
+	Rules []AlertRuleRequest `validate:"omitempty,dive" json:"rules" form:"rules"`
+	// CaptchaToken is verified by the handler when captcha is configured
+	CaptchaToken string `json:"captchaToken" form:"captchaToken"`
 }
 
 type SubscriptionManager struct {
 	cfg             *config.Config
 	state           state.Stateful
 	mapsIntegration integrations.MapsIntegration
-	mailer          mailer2.MailerService
+	suppressions    suppressions.SuppressionManager
+	cities          cities.CityManager
 }
 
-func New(config *config.Config, state state.Stateful, mailer mailer2.MailerService, integration integrations.MapsIntegration) SubManager {
+func New(config *config.Config, state state.Stateful, integration integrations.MapsIntegration) SubManager {
 	return &SubscriptionManager{
 		cfg:             config,
 		state:           state,
-		mailer:          mailer,
 		mapsIntegration: integration,
+		suppressions:    suppressions.New(state),
+		cities:          cities.New(config, state, integration),
 	}
 }
 
-// InviteUser accepts user request for subscription, finds or creates city, creates user record,
-// creates confirmation token and sends it to user email
+// InviteUser accepts user request for subscription, finds or creates city and user records,
+// creates pending subscription with its confirmation token and enqueues it to user email
+// in the same transaction. Suppressed addresses are rejected
 func (s *SubscriptionManager) InviteUser(ctx context.Context, request SubscribeRequest) error {
-	city, err := s.state.GetCity(request.City)
-	if err != nil && errors.Is(gorm.ErrRecordNotFound, err) { // Make sure models is imported if not already
-		city, err = s.mapsIntegration.GetCity(ctx, slug.Make(request.City))
+	if request.Language != "" && !templates.SupportedLanguage(request.Language) {
+		return ErrUnsupportedLanguage
+	}
+	suppressed, err := s.suppressions.IsSuppressed(request.Email)
+	if err != nil {
+		return err
+	}
+	if suppressed {
+		return ErrEmailSuppressed
+	}
+
+	var rules []*models.AlertRule
+	if models.SubscriptionType(request.Frequency) == models.ALERT {
+		rules, err = newAlertRules(request.Rules)
 		if err != nil {
-			zap.L().Error("error getting city", zap.Error(err))
 			return err
 		}
-		err = s.state.SaveCity(city)
+	}
+
+	city, err := s.requestCity(ctx, request)
+	if err != nil {
+		return err
+	}
+
+	timezone, err := s.subscriptionTimezone(ctx, city, request.Timezone)
+	if err != nil {
+		return err
+	}
+
+	return s.state.Transaction(func(tx state.Stateful) error {
+		return s.createSubscription(tx, request, city, timezone, rules)
+	})
+}
+
+// requestCity returns city of the request coordinates or of its name
+func (s *SubscriptionManager) requestCity(ctx context.Context, request SubscribeRequest) (*models.City, error) {
+	if request.Lat != nil && request.Lon != nil {
+		return s.cities.FindAt(ctx, *request.Lat, *request.Lon)
+	}
+
+	return s.cities.Find(ctx, request.City)
+}
+
+// createSubscription finds or creates user, saves pending subscription with its tokens
+// and enqueues confirmation email
+func (s *SubscriptionManager) createSubscription(
+	tx state.Stateful,
+	request SubscribeRequest,
+	city *models.City,
+	timezone string,
+	rules []*models.AlertRule,
+) error {
+	user, err := tx.GetUserByEmail(request.Email)
+	if err != nil && !errors.Is(err, state.ErrNotFound) {
+		return err
+	}
+	if user == nil {
+		user = &models.User{
+			ID:       uuid.Must(uuid.NewV7()).String(),
+			Email:    request.Email,
+			Units:    string(models.Metric),
+			Language: models.DefaultLanguage,
+		}
+		if request.Units != "" {
+			user.Units = request.Units
+		}
+		if request.Language != "" {
+			user.Language = request.Language
+		}
+		err = tx.SaveUser(user)
 		if err != nil {
-			zap.L().Error("error saving city", zap.Error(err))
+			zap.L().Error("error saving user", zap.Error(err))
 			return err
 		}
-	} else if err != nil {
-		zap.L().Error("error getting city", zap.Error(err))
-		return err
 	}
 
-	user, err := s.state.GetUserByEmail(request.Email)
-	if err != nil && !errors.Is(gorm.ErrRecordNotFound, err) {
+	subscription, err := tx.GetUserSubscription(user.ID, city.ID)
+	if err != nil && !errors.Is(err, state.ErrNotFound) {
 		return err
 	}
-	if user != nil {
-		return errors.New("user already exists")
+	if subscription != nil && subscription.Confirmed {
+		return ErrSubscriptionExists
 	}
-	user = &models.User{
-		ID:     uuid.Must(uuid.NewV7()).String(),
-		Email:  request.Email,
-		CityID: city.ID,
-		City:   *city,
+	if subscription == nil {
+		subscription = &models.Subscription{
+			ID:     uuid.Must(uuid.NewV7()).String(),
+			UserID: user.ID,
+			CityID: city.ID,
+		}
+	}
+	// pending subscription is updated with the latest requested preferences
+	subscription.Frequency = request.Frequency
+	subscription.Timezone = timezone
+	subscription.DeliveryHour = defaultDeliveryHour
+	if request.DeliveryHour != nil {
+		subscription.DeliveryHour = *request.DeliveryHour
 	}
-	err = s.state.SaveUser(user)
+	err = tx.SaveSubscription(subscription)
 	if err != nil {
-		zap.L().Error("error saving user", zap.Error(err))
+		zap.L().Error("error saving subscription", zap.Error(err))
 		return err
 	}
+	if rules != nil {
+		err = saveAlertRules(tx, subscription.ID, rules)
+		if err != nil {
+			zap.L().Error("error saving alert rules", zap.Error(err))
+			return err
+		}
+	}
 
 	// create confirmation code
-	token, err := s.createToken(user.ID, models.Sub, &request.Frequency)
+	token, err := createToken(tx, s.cfg.ManageTokenSecret, subscription.ID, models.Sub)
 	if err != nil {
 		zap.L().Error("error creating sub token", zap.Error(err))
 		return err
 	}
 	// create code to unsubscribe
-	_, err = s.createToken(user.ID, models.Unsub, nil)
+	_, err = createToken(tx, s.cfg.ManageTokenSecret, subscription.ID, models.Unsub)
 	if err != nil {
 		zap.L().Error("error creating unsub token", zap.Error(err))
 		return err
 	}
+	// create code to manage subscription preferences
+	manageToken, err := createToken(tx, s.cfg.ManageTokenSecret, subscription.ID, models.Manage)
+	if err != nil {
+		zap.L().Error("error creating manage token", zap.Error(err))
+		return err
+	}
 
-	err = s.mailer.Send(mailer2.MailMessage{
+	locale := templates.NewLocale(user.Language, models.Units(user.Units))
+	body, err := templates.GetVerificationEmailTemplate(locale, s.cfg.FrontendURL, token.Token, manageToken.Token)
+	if err != nil {
+		zap.L().Error("error rendering confirmation email", zap.Error(err))
+		return err
+	}
+	err = outbox.Enqueue(tx, mailer.MailMessage{
 		To:      []string{user.Email},
-		Subject: "Confirmation code",
-		Body:    templates.GetVerificationEmailTemplate(s.cfg.FrontendURL, token.Token),
+		Subject: locale.Text("subject.verification"),
+		Body:    body,
 	})
 	if err != nil {
-		zap.L().Error("error sending confirmation email", zap.Error(err))
+		zap.L().Error("error enqueueing confirmation email", zap.Error(err))
 		return err
 	}
 
 	return nil
 }
 
-// Subscribe checks if sub token exists and creates subscription for the user
+// subscriptionTimezone validates requested timezone, if it is empty falls back to the city timezone.
+// Unknown city timezone is taken from the weather provider response
+func (s *SubscriptionManager) subscriptionTimezone(ctx context.Context, city *models.City, requested string) (string, error) {
+	if requested != "" {
+		if _, err := time.LoadLocation(requested); err != nil {
+			return "", ErrInvalidTimezone
+		}
+		return requested, nil
+	}
+	if city.TimeZone != "" {
+		return city.TimeZone, nil
+	}
+
+	weather, err := s.mapsIntegration.GetWeather(ctx, city)
+	if err != nil || city.TimeZone == "" {
+		zap.L().Warn("could not resolve city timezone", zap.String("city", city.Name), zap.Error(err))
+		return defaultTimezone, nil
+	}
+	err = s.state.SaveCity(city)
+	if err != nil {
+		zap.L().Error("error saving city", zap.Error(err))
+		return "", err
+	}
+	err = s.state.SaveWeather(weather)
+	if err != nil {
+		zap.L().Error("error saving weather", zap.Error(err))
+	}
+
+	return city.TimeZone, nil
+}
+
+// Subscribe checks if sub token exists and confirms the subscription it was issued for
 func (s *SubscriptionManager) Subscribe(token string) error {
 	userToken, err := s.verifyToken(token)
-	fmt.Println("verified token")
 	if err != nil {
-		fmt.Println("error verifying token", err)
-		return errors.New("invalid token")
+		return err
 	}
 	if userToken.Type != string(models.Sub) {
-		return errors.New("invalid token")
+		return ErrInvalidToken
 	}
 
-	subscription := &models.Subscription{
-		ID:        uuid.Must(uuid.NewV7()).String(),
-		Frequency: userToken.SubscriptionType,
-		UserID:    userToken.UserID,
+	subscription, err := s.state.GetSubscription(userToken.SubscriptionID)
+	if err != nil {
+		zap.L().Error("error getting subscription", zap.Error(err))
+		return err
 	}
-	err = s.state.SaveSubscription(subscription)
+	// cached subscription is not touched until it is saved
+	confirmed := *subscription
+	confirmed.Confirmed = true
+	err = s.state.SaveSubscription(&confirmed)
 	if err != nil {
 		zap.L().Error("error saving subscription", zap.Error(err))
 		return err
@@ -142,26 +287,46 @@ func (s *SubscriptionManager) Subscribe(token string) error {
 	return nil
 }
 
-// Unsubscribe checks if unsub token exists and deletes user, and all related records
+// Unsubscribe checks if unsub token exists and deletes the subscription with its tokens.
+// The user is removed as well once the last subscription is gone
 func (s *SubscriptionManager) Unsubscribe(token string) error {
-	userToken, err := s.verifyToken(token)
+	userToken, err := s.verifySignedToken(token, models.Unsub)
 	if err != nil {
-		return errors.New("invalid token")
-	}
-	if userToken.Type != string(models.Unsub) {
-		return errors.New("invalid token")
-	}
-
-	err = s.state.RemoveUser(&models.User{ID: userToken.UserID})
-	if err != nil {
-		zap.L().Error("error removing user", zap.Error(err))
 		return err
 	}
-	err = s.state.RemoveToken(userToken)
+
+	subscription, err := s.state.GetSubscription(userToken.SubscriptionID)
 	if err != nil {
-		zap.L().Error("error removing token", zap.Error(err))
+		zap.L().Error("error getting subscription", zap.Error(err))
 		return err
 	}
 
-	return nil
+	return s.removeSubscription(subscription)
+}
+
+// removeSubscription deletes the subscription with its tokens, the user is removed with the last subscription.
+// Both are removed in one transaction, so a failure does not leave a user without subscriptions
+func (s *SubscriptionManager) removeSubscription(subscription *models.Subscription) error {
+	return s.state.Transaction(func(tx state.Stateful) error {
+		err := tx.RemoveSubscription(subscription)
+		if err != nil {
+			zap.L().Error("error removing subscription", zap.Error(err))
+			return err
+		}
+
+		remaining, err := tx.GetUserSubscriptions(subscription.UserID)
+		if err != nil {
+			zap.L().Error("error getting user subscriptions", zap.Error(err))
+			return err
+		}
+		if len(remaining) == 0 {
+			err = tx.RemoveUser(&models.User{ID: subscription.UserID})
+			if err != nil {
+				zap.L().Error("error removing user", zap.Error(err))
+				return err
+			}
+		}
+
+		return nil
+	})
 }
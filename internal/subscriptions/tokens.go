@@ -1,83 +1,130 @@
 package subscriptions
 
 import (
+	"crypto/hmac"
 	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
 	"errors"
-	"gorm.io/gorm"
+	"strings"
 	"time"
 	"weather-subscriptions/internal/db/models"
+	"weather-subscriptions/internal/state"
 )
 
 const (
-	subTokenDuration   = 24 * time.Hour
-	unsubTokenDuration = time.Hour * 24 * 28 * 13 * 100
+	subTokenDuration    = 24 * time.Hour
+	unsubTokenDuration  = time.Hour * 24 * 28 * 13 * 100
+	manageTokenDuration = unsubTokenDuration
+	manageTokenLength   = 24
 )
 
+// verifyToken returns ErrInvalidToken for unknown and expired tokens
 func (s *SubscriptionManager) verifyToken(token string) (*models.Token, error) {
 	foundToken, err := s.state.GetToken(token)
+	if errors.Is(err, state.ErrNotFound) {
+		return nil, ErrInvalidToken
+	}
 	if err != nil {
 		return nil, err
 	}
 	if foundToken.ExpiryAt.Before(time.Now()) {
-		return nil, errors.New("token expired")
+		return nil, ErrInvalidToken
 	}
 	if foundToken.DeletedAt.Valid && foundToken.DeletedAt.Time.Before(time.Now()) {
-		return nil, errors.New("token is expired")
+		return nil, ErrInvalidToken
 	}
 	return foundToken, nil
 }
 
-func (s *SubscriptionManager) createToken(userID string, tokenType models.TokenType, frequency *string) (*models.Token, error) {
-	code, err := generateCode()
+// verifyManageToken checks manage token signature before looking it up, so forged tokens never reach database
+func (s *SubscriptionManager) verifyManageToken(token string) (*models.Token, error) {
+	return s.verifySignedToken(token, models.Manage)
+}
+
+// verifySignedToken checks signature of the long-lived token before looking it up and verifies its type
+func (s *SubscriptionManager) verifySignedToken(token string, tokenType models.TokenType) (*models.Token, error) {
+	if !verifySignature(s.cfg.ManageTokenSecret, token) {
+		return nil, ErrInvalidToken
+	}
+	userToken, err := s.verifyToken(token)
 	if err != nil {
-		return nil, errors.New("failed to generate code")
+		return nil, err
+	}
+	if userToken.Type != string(tokenType) {
+		return nil, ErrInvalidToken
 	}
 
-	var token *models.Token
-	if tokenType == models.Sub {
-		foundToken, err := s.state.GetSubToken(userID)
-		if err != nil && !errors.Is(gorm.ErrRecordNotFound, err) {
-			return nil, err
-		}
-		if foundToken != nil && foundToken.ExpiryAt.Add(subTokenDuration).Before(time.Now()) {
-			return nil, errors.New("token already exists")
-		} else if foundToken != nil {
-			err = s.state.RemoveToken(foundToken)
-			if err != nil {
-				return nil, err
-			}
-		}
+	return userToken, nil
+}
 
-		token = &models.Token{
-			Token:            code,
-			Type:             string(tokenType),
-			SubscriptionType: *frequency,
-			ExpiryAt:         time.Now().Add(subTokenDuration),
-			UserID:           userID,
-		}
-	} else {
-		foundToken, err := s.state.GetUnsubToken(userID)
-		if err != nil && !errors.Is(gorm.ErrRecordNotFound, err) {
-			return nil, err
-		}
-		if foundToken != nil && foundToken.ExpiryAt.Add(subTokenDuration).Before(time.Now()) {
-			return nil, errors.New("token already exists")
-		} else if foundToken != nil {
-			err = s.state.RemoveToken(foundToken)
-			if err != nil {
-				return nil, err
-			}
-		}
+// ManageToken returns manage token of the subscription, the token is issued if subscription has none yet
+func ManageToken(tx state.Stateful, secret, subscriptionID string) (*models.Token, error) {
+	token, err := tx.GetManageToken(subscriptionID)
+	if err == nil {
+		return token, nil
+	}
+	if !errors.Is(err, state.ErrNotFound) {
+		return nil, err
+	}
+
+	return createToken(tx, secret, subscriptionID, models.Manage)
+}
+
+// UnsubToken returns unsubscribe token of the subscription, unsigned token issued before unsubscribe tokens
+// were signed is replaced by a signed one
+func UnsubToken(tx state.Stateful, secret, subscriptionID string) (*models.Token, error) {
+	token, err := tx.GetUnsubToken(subscriptionID)
+	if err == nil && verifySignature(secret, token.Token) {
+		return token, nil
+	}
+	if err != nil && !errors.Is(err, state.ErrNotFound) {
+		return nil, err
+	}
+
+	return createToken(tx, secret, subscriptionID, models.Unsub)
+}
 
-		token = &models.Token{
-			Token:    code,
-			Type:     string(tokenType),
-			ExpiryAt: time.Now().Add(unsubTokenDuration),
-			UserID:   userID,
+func createToken(tx state.Stateful, secret, subscriptionID string, tokenType models.TokenType) (*models.Token, error) {
+	code, err := newCode(secret, tokenType)
+	if err != nil {
+		return nil, errors.New("failed to generate code")
+	}
+
+	var (
+		foundToken *models.Token
+		duration   time.Duration
+	)
+	switch tokenType {
+	case models.Sub:
+		foundToken, err = tx.GetSubToken(subscriptionID)
+		duration = subTokenDuration
+	case models.Manage:
+		foundToken, err = tx.GetManageToken(subscriptionID)
+		duration = manageTokenDuration
+	default:
+		foundToken, err = tx.GetUnsubToken(subscriptionID)
+		duration = unsubTokenDuration
+	}
+	if err != nil && !errors.Is(err, state.ErrNotFound) {
+		return nil, err
+	}
+	if foundToken != nil && foundToken.ExpiryAt.Add(subTokenDuration).Before(time.Now()) {
+		return nil, errors.New("token already exists")
+	} else if foundToken != nil {
+		err = tx.RemoveToken(foundToken)
+		if err != nil {
+			return nil, err
 		}
 	}
 
-	err = s.state.SaveToken(token)
+	token := &models.Token{
+		Token:          code,
+		Type:           string(tokenType),
+		ExpiryAt:       time.Now().Add(duration),
+		SubscriptionID: subscriptionID,
+	}
+	err = tx.SaveToken(token)
 	if err != nil {
 		return nil, errors.New("failed to save token")
 	}
@@ -85,6 +132,15 @@ func (s *SubscriptionManager) createToken(userID string, tokenType models.TokenT
 	return token, nil
 }
 
+// newCode returns signed code for long-lived manage and unsub tokens and short numeric code for sub tokens
+func newCode(secret string, tokenType models.TokenType) (string, error) {
+	if tokenType == models.Manage || tokenType == models.Unsub {
+		return signedCode(secret)
+	}
+
+	return generateCode()
+}
+
 func generateCode() (string, error) {
 	codes := make([]byte, emailValidationCodeLength)
 	if _, err := rand.Read(codes); err != nil {
@@ -97,3 +153,29 @@ func generateCode() (string, error) {
 
 	return string(codes), nil
 }
+
+// signedCode generates random code followed by its HMAC signature, "<code>.<signature>"
+func signedCode(secret string) (string, error) {
+	code := make([]byte, manageTokenLength)
+	if _, err := rand.Read(code); err != nil {
+		return "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(code)
+
+	return encoded + "." + sign(secret, encoded), nil
+}
+
+func verifySignature(secret, token string) bool {
+	code, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return false
+	}
+
+	return hmac.Equal([]byte(signature), []byte(sign(secret, code)))
+}
+
+func sign(secret, code string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(code))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
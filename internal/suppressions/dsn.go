@@ -0,0 +1,131 @@
+package suppressions
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"net/textproto"
+	"strings"
+)
+
+// ParseMbox reads delivery status notifications (RFC 3464) and abuse feedback reports (RFC 5965)
+// from mbox, other messages are skipped
+func ParseMbox(r io.Reader) ([]Event, error) {
+	var events []Event
+	var message bytes.Buffer
+	flush := func() {
+		if message.Len() > 0 {
+			events = append(events, ParseReport(message.Bytes())...)
+			message.Reset()
+		}
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 10*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "From ") {
+			flush()
+			continue
+		}
+		// mbox quotes body lines which start with "From "
+		if strings.HasPrefix(line, ">From ") {
+			line = line[1:]
+		}
+		message.WriteString(line + "\r\n")
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	flush()
+
+	return events, nil
+}
+
+// ParseReport returns events of a single multipart/report message
+func ParseReport(raw []byte) []Event {
+	message, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return nil
+	}
+	mediaType, params, err := mime.ParseMediaType(message.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/report" {
+		return nil
+	}
+
+	var events []Event
+	reader := multipart.NewReader(message.Body, params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if err != nil {
+			return events
+		}
+		content, err := io.ReadAll(part)
+		if err != nil {
+			return events
+		}
+		partType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		switch partType {
+		case "message/delivery-status", "message/global-delivery-status":
+			events = append(events, deliveryStatusEvents(content)...)
+		case "message/feedback-report":
+			events = append(events, feedbackEvents(content)...)
+		}
+	}
+}
+
+// deliveryStatusEvents returns bounces of failed recipients, 5.x.x status is a permanent failure
+func deliveryStatusEvents(content []byte) []Event {
+	reader := textproto.NewReader(bufio.NewReader(bytes.NewReader(append(content, "\r\n"...))))
+	// the first block holds per-message fields
+	if _, err := reader.ReadMIMEHeader(); err != nil {
+		return nil
+	}
+
+	var events []Event
+	for {
+		fields, err := reader.ReadMIMEHeader()
+		if len(fields) > 0 && strings.EqualFold(fields.Get("Action"), "failed") {
+			status := fields.Get("Status")
+			events = append(events, Event{
+				Email:     address(fields.Get("Final-Recipient"), fields.Get("Original-Recipient")),
+				Type:      Bounce,
+				Permanent: strings.HasPrefix(status, "5"),
+				Detail:    firstNonEmpty(fields.Get("Diagnostic-Code"), status),
+			})
+		}
+		if err != nil {
+			return events
+		}
+	}
+}
+
+// feedbackEvents returns complaint of the report recipient
+func feedbackEvents(content []byte) []Event {
+	reader := textproto.NewReader(bufio.NewReader(bytes.NewReader(append(content, "\r\n"...))))
+	fields, _ := reader.ReadMIMEHeader()
+	email := address(fields.Get("Original-Rcpt-To"))
+	if email == "" {
+		return nil
+	}
+
+	return []Event{{Email: email, Type: Complaint, Detail: fields.Get("Feedback-Type")}}
+}
+
+// address returns address of the first non-empty "type; address" field
+func address(fields ...string) string {
+	for _, field := range fields {
+		if _, value, found := strings.Cut(field, ";"); found {
+			field = value
+		}
+		field = strings.Trim(strings.TrimSpace(field), "<>")
+		if field != "" {
+			return field
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,10 @@
+package suppressions
+
+import "errors"
+
+// Errors returned by SuppressionManager and webhook parsers for invalid requests
+var (
+	ErrSuppressionNotFound = errors.New("suppression not found")
+	ErrUnknownProvider     = errors.New("unknown provider")
+	ErrInvalidPayload      = errors.New("invalid webhook payload")
+)
@@ -0,0 +1,177 @@
+package suppressions
+
+import (
+	"errors"
+	"go.uber.org/zap"
+	"strings"
+	"time"
+	"weather-subscriptions/internal/db/models"
+	"weather-subscriptions/internal/state"
+)
+
+// EventType is kind of a delivery notification
+type EventType string
+
+const (
+	Bounce    EventType = "bounce"
+	Complaint EventType = "complaint"
+)
+
+// Event is a bounce or complaint notification of a recipient. Complaints and permanent bounces
+// suppress the address, transient bounces are ignored
+type Event struct {
+	Email     string    `json:"email" validate:"required,email"`
+	Type      EventType `json:"type" validate:"required,oneof=bounce complaint"`
+	Permanent bool      `json:"permanent"`
+	Detail    string    `json:"detail"`
+}
+
+type SuppressionManager interface {
+	Ingest(source string, events []Event) (int, error)
+	IsSuppressed(email string) (bool, error)
+	List() ([]SuppressionView, error)
+	Add(request AddRequest) (*SuppressionView, error)
+	Remove(email string) error
+}
+
+// SuppressionView is a suppressed address shown by admin API
+type SuppressionView struct {
+	Email     string    `json:"email"`
+	Reason    string    `json:"reason"`
+	Source    string    `json:"source"`
+	Detail    string    `json:"detail,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// AddRequest suppresses an address manually
+type AddRequest struct {
+	Email  string `validate:"required,email" json:"email"`
+	Detail string `json:"detail"`
+}
+
+type Manager struct {
+	state state.Stateful
+}
+
+func New(state state.Stateful) SuppressionManager {
+	return &Manager{state: state}
+}
+
+// Ingest suppresses addresses of complaints and permanent bounces and returns number of suppressed addresses
+func (m *Manager) Ingest(source string, events []Event) (int, error) {
+	suppressed := 0
+	for _, event := range events {
+		if event.Email == "" || (event.Type == Bounce && !event.Permanent) {
+			continue
+		}
+		reason := models.SuppressedBounce
+		if event.Type == Complaint {
+			reason = models.SuppressedComplaint
+		} else if event.Type != Bounce {
+			continue
+		}
+
+		err := m.suppress(normalize(event.Email), reason, source, event.Detail)
+		if err != nil {
+			return suppressed, err
+		}
+		suppressed++
+	}
+
+	return suppressed, nil
+}
+
+// suppress saves the suppression, complaint is kept when the address bounces later
+func (m *Manager) suppress(email string, reason models.SuppressionReason, source, detail string) error {
+	suppression, err := m.state.GetSuppression(email)
+	if err != nil && !errors.Is(err, state.ErrNotFound) {
+		return err
+	}
+	if suppression == nil {
+		suppression = &models.Suppression{Email: email}
+	}
+	if suppression.Reason != string(models.SuppressedComplaint) {
+		suppression.Reason = string(reason)
+	}
+	suppression.Source = source
+	suppression.Detail = detail
+
+	err = m.state.SaveSuppression(suppression)
+	if err != nil {
+		zap.L().Error("error saving suppression", zap.Error(err))
+		return err
+	}
+	zap.L().Info("address suppressed", zap.String("reason", string(reason)), zap.String("source", source))
+
+	return nil
+}
+
+// IsSuppressed reports whether emails to the address are suppressed
+func (m *Manager) IsSuppressed(email string) (bool, error) {
+	_, err := m.state.GetSuppression(normalize(email))
+	if errors.Is(err, state.ErrNotFound) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+// List returns suppressed addresses, the latest first
+func (m *Manager) List() ([]SuppressionView, error) {
+	suppressions, err := m.state.GetSuppressions()
+	if err != nil {
+		return nil, err
+	}
+
+	views := make([]SuppressionView, 0, len(suppressions))
+	for _, suppression := range suppressions {
+		views = append(views, newView(suppression))
+	}
+
+	return views, nil
+}
+
+// Add suppresses the address manually
+func (m *Manager) Add(request AddRequest) (*SuppressionView, error) {
+	email := normalize(request.Email)
+	err := m.suppress(email, models.SuppressedManual, "admin", request.Detail)
+	if err != nil {
+		return nil, err
+	}
+	suppression, err := m.state.GetSuppression(email)
+	if err != nil {
+		return nil, err
+	}
+
+	view := newView(suppression)
+	return &view, nil
+}
+
+// Remove lifts suppression of the address
+func (m *Manager) Remove(email string) error {
+	suppression, err := m.state.GetSuppression(normalize(email))
+	if errors.Is(err, state.ErrNotFound) {
+		return ErrSuppressionNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	return m.state.RemoveSuppression(suppression)
+}
+
+func newView(suppression *models.Suppression) SuppressionView {
+	return SuppressionView{
+		Email:     suppression.Email,
+		Reason:    suppression.Reason,
+		Source:    suppression.Source,
+		Detail:    suppression.Detail,
+		CreatedAt: suppression.CreatedAt,
+		UpdatedAt: suppression.UpdatedAt,
+	}
+}
+
+func normalize(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
@@ -0,0 +1,178 @@
+package suppressions
+
+import (
+	"github.com/go-playground/validator"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+
+	body, err := os.ReadFile(filepath.Join("testdata", name))
+	require.NoError(t, err)
+
+	return body
+}
+
+func TestParseMbox(t *testing.T) {
+	file, err := os.Open(filepath.Join("testdata", "bounces.mbox"))
+	require.NoError(t, err)
+	defer file.Close()
+
+	events, err := ParseMbox(file)
+	require.NoError(t, err)
+
+	assert.Equal(t, []Event{
+		{
+			Email:     "missing@example.org",
+			Type:      Bounce,
+			Permanent: true,
+			Detail:    "smtp; 550 5.1.1 <missing@example.org>: Recipient address rejected: User unknown in virtual mailbox table",
+		},
+		{
+			Email:  "full@example.net",
+			Type:   Bounce,
+			Detail: "smtp; 452 4.2.2 Mailbox full",
+		},
+		{
+			Email:  "complainer@example.net",
+			Type:   Complaint,
+			Detail: "abuse",
+		},
+	}, events)
+}
+
+func TestParseReportSkipsOtherMessages(t *testing.T) {
+	tests := []struct {
+		name    string
+		message string
+	}{
+		{name: "plain message", message: "From: weather@example.com\r\nContent-Type: text/plain\r\n\r\nbody\r\n"},
+		{name: "malformed headers", message: "not a message"},
+		{
+			name: "delivery status without failures",
+			message: "Content-Type: multipart/report; boundary=b\r\n\r\n--b\r\n" +
+				"Content-Type: message/delivery-status\r\n\r\n" +
+				"Reporting-MTA: dns; mail.example.com\r\n\r\n" +
+				"Final-Recipient: rfc822; user@example.org\r\nAction: delayed\r\nStatus: 4.4.1\r\n" +
+				"--b--\r\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Empty(t, ParseReport([]byte(test.message)))
+		})
+	}
+}
+
+func TestParseWebhook(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider string
+		fixture  string
+		want     []Event
+	}{
+		{
+			name:     "SES bounce delivered by SNS",
+			provider: "ses",
+			fixture:  "ses_bounce.json",
+			want: []Event{
+				{Email: "missing@example.org", Type: Bounce, Permanent: true, Detail: "smtp; 550 5.1.1 user unknown"},
+				{Email: "gone@example.org", Type: Bounce, Permanent: true, Detail: "General"},
+			},
+		},
+		{
+			name:     "raw SES complaint",
+			provider: "ses",
+			fixture:  "ses_complaint.json",
+			want:     []Event{{Email: "complainer@example.net", Type: Complaint, Detail: "abuse"}},
+		},
+		{
+			name:     "SendGrid batch",
+			provider: "sendgrid",
+			fixture:  "sendgrid_batch.json",
+			want: []Event{
+				{
+					Email:     "missing@example.org",
+					Type:      Bounce,
+					Permanent: true,
+					Detail:    "550 5.1.1 The email account that you tried to reach does not exist",
+				},
+				{Email: "blocked@example.net", Type: Bounce, Detail: "421 4.7.0 Try again later, closing connection"},
+				{Email: "complainer@example.net", Type: Complaint, Detail: "spamreport"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			events, err := ParseWebhook(test.provider, readFixture(t, test.fixture))
+			require.NoError(t, err)
+			assert.Equal(t, test.want, events)
+		})
+	}
+}
+
+func TestParseWebhookSubscriptionConfirmation(t *testing.T) {
+	body := `{"Type": "SubscriptionConfirmation", "SubscribeURL": "https://sns.us-east-1.amazonaws.com/?Action=ConfirmSubscription"}`
+
+	events, err := ParseWebhook("ses", []byte(body))
+
+	require.NoError(t, err)
+	assert.Empty(t, events)
+}
+
+func TestParseWebhookRejectsInvalidPayloads(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider string
+		body     string
+		invalid  bool
+	}{
+		{name: "malformed JSON", provider: "sendgrid", body: `{"email":`},
+		{name: "SNS message which is not a notification", provider: "ses", body: `{"Type": "Notification", "Message": "bounce"}`},
+		{
+			name:     "generic event without type",
+			provider: "generic",
+			body:     `{"events": [{"email": "user@example.org"}]}`,
+			invalid:  true,
+		},
+		{
+			name:     "SES bounce of invalid address",
+			provider: "ses",
+			body:     `{"notificationType": "Bounce", "bounce": {"bounceType": "Permanent", "bouncedRecipients": [{"emailAddress": "unknown"}]}}`,
+			invalid:  true,
+		},
+		{
+			name:     "SendGrid bounce without address",
+			provider: "sendgrid",
+			body:     `[{"email": "user@example.org", "event": "spamreport"}, {"event": "bounce", "type": "bounce"}]`,
+			invalid:  true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			events, err := ParseWebhook(test.provider, []byte(test.body))
+
+			assert.Nil(t, events)
+			if test.invalid {
+				var validationErrors validator.ValidationErrors
+				assert.ErrorAs(t, err, &validationErrors)
+			} else {
+				assert.ErrorIs(t, err, ErrInvalidPayload)
+			}
+		})
+	}
+}
+
+func TestParseWebhookUnknownProvider(t *testing.T) {
+	_, err := ParseWebhook("mailgun", []byte(`{}`))
+
+	assert.ErrorIs(t, err, ErrUnknownProvider)
+}
@@ -0,0 +1,167 @@
+package suppressions
+
+import (
+	"encoding/json"
+	"fmt"
+	"go.uber.org/zap"
+	"strings"
+	"weather-subscriptions/internal/validation"
+)
+
+// parsers convert webhook payloads of a provider to events
+var parsers = map[string]func(body []byte) ([]Event, error){
+	"generic":  parseGeneric,
+	"ses":      parseSES,
+	"sendgrid": parseSendGrid,
+}
+
+// ParseWebhook returns events of the provider webhook payload, events of every provider are validated,
+// so a malformed notification never suppresses an address
+func ParseWebhook(provider string, body []byte) ([]Event, error) {
+	parse, ok := parsers[provider]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+	events, err := parse(body)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidPayload, err)
+	}
+	for _, event := range events {
+		err = validation.Struct(&event)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return events, nil
+}
+
+// genericPayload is {"events": [{"email", "type": "bounce"|"complaint", "permanent", "detail"}]}
+type genericPayload struct {
+	Events []Event `json:"events"`
+}
+
+func parseGeneric(body []byte) ([]Event, error) {
+	var payload genericPayload
+	err := json.Unmarshal(body, &payload)
+	if err != nil {
+		return nil, err
+	}
+
+	return payload.Events, nil
+}
+
+// snsMessage is SNS envelope of SES notifications
+type snsMessage struct {
+	Type         string `json:"Type"`
+	Message      string `json:"Message"`
+	SubscribeURL string `json:"SubscribeURL"`
+}
+
+type sesRecipient struct {
+	EmailAddress   string `json:"emailAddress"`
+	DiagnosticCode string `json:"diagnosticCode"`
+}
+
+type sesNotification struct {
+	NotificationType string `json:"notificationType"`
+	Bounce           struct {
+		BounceType        string         `json:"bounceType"`
+		BounceSubType     string         `json:"bounceSubType"`
+		BouncedRecipients []sesRecipient `json:"bouncedRecipients"`
+	} `json:"bounce"`
+	Complaint struct {
+		ComplaintFeedbackType string         `json:"complaintFeedbackType"`
+		ComplainedRecipients  []sesRecipient `json:"complainedRecipients"`
+	} `json:"complaint"`
+}
+
+// parseSES accepts SES notifications delivered either by SNS or as raw message
+func parseSES(body []byte) ([]Event, error) {
+	var envelope snsMessage
+	err := json.Unmarshal(body, &envelope)
+	if err != nil {
+		return nil, err
+	}
+	switch envelope.Type {
+	case "SubscriptionConfirmation":
+		// topic subscription is confirmed by operator, the endpoint never follows links of payloads
+		zap.L().Info("SNS subscription confirmation received", zap.String("subscribeURL", envelope.SubscribeURL))
+		return nil, nil
+	case "Notification":
+		body = []byte(envelope.Message)
+	}
+
+	var notification sesNotification
+	err = json.Unmarshal(body, &notification)
+	if err != nil {
+		return nil, err
+	}
+
+	var events []Event
+	switch notification.NotificationType {
+	case "Bounce":
+		for _, recipient := range notification.Bounce.BouncedRecipients {
+			events = append(events, Event{
+				Email:     recipient.EmailAddress,
+				Type:      Bounce,
+				Permanent: notification.Bounce.BounceType == "Permanent",
+				Detail:    firstNonEmpty(recipient.DiagnosticCode, notification.Bounce.BounceSubType),
+			})
+		}
+	case "Complaint":
+		for _, recipient := range notification.Complaint.ComplainedRecipients {
+			events = append(events, Event{
+				Email:  recipient.EmailAddress,
+				Type:   Complaint,
+				Detail: notification.Complaint.ComplaintFeedbackType,
+			})
+		}
+	}
+
+	return events, nil
+}
+
+// sendGridEvent is an item of SendGrid event webhook batch
+type sendGridEvent struct {
+	Email  string `json:"email"`
+	Event  string `json:"event"`
+	Type   string `json:"type"`
+	Reason string `json:"reason"`
+}
+
+// parseSendGrid maps bounces and spam reports, "blocked" bounces are transient
+func parseSendGrid(body []byte) ([]Event, error) {
+	var batch []sendGridEvent
+	err := json.Unmarshal(body, &batch)
+	if err != nil {
+		return nil, err
+	}
+
+	var events []Event
+	for _, item := range batch {
+		switch item.Event {
+		case "bounce":
+			events = append(events, Event{
+				Email:     item.Email,
+				Type:      Bounce,
+				Permanent: !strings.EqualFold(item.Type, "blocked"),
+				Detail:    item.Reason,
+			})
+		case "spamreport":
+			events = append(events, Event{Email: item.Email, Type: Complaint, Detail: "spamreport"})
+		}
+	}
+
+	return events, nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,124 @@
+package templates
+
+import (
+	"html/template"
+	"strings"
+	"time"
+	"weather-subscriptions/internal/db/models"
+)
+
+// funcs returns template functions which format strings and values in the locale
+func funcs(locale *Locale) template.FuncMap {
+	return template.FuncMap{
+		"t":    locale.Text,
+		"lang": func() string { return locale.Language },
+		"number": func(value any, precision int) string {
+			return locale.number(toFloat(value), precision)
+		},
+		"percent": func(value int) string {
+			return locale.number(float64(value), 0) + "%"
+		},
+		"measure": func(value float64, precision int, quantity string) string {
+			return locale.number(value, precision) + " " + locale.unit(quantity)
+		},
+		"temperature": func(degrees float64) string { return locale.temperature(degrees, false) },
+		"degrees":     func(degrees float64) string { return locale.temperature(degrees, true) },
+		"date":        func(date time.Time) string { return locale.date(date, false) },
+		"shortDate":   func(date time.Time) string { return locale.date(date, true) },
+		"wind":        func(weather *models.Weather) string { return formatWind(locale, weather) },
+		"precipitation": func(weather *models.Weather) string {
+			return formatPrecipitation(locale, weather)
+		},
+		"history": func(history models.WeatherHistory) string { return formatHistory(locale, history) },
+		"rule":    func(rule models.AlertRule) string { return formatRule(locale, rule) },
+		"item": func(icon, label, value, class string) weatherItem {
+			return weatherItem{Icon: icon, Label: label, Value: value, Class: class}
+		},
+		"options": func(current string, values []string) selectOptions {
+			return selectOptions{Current: current, Values: values}
+		},
+	}
+}
+
+// weatherItem is a labeled row of weather partial
+type weatherItem struct {
+	Icon  string
+	Label string
+	Value string
+	Class string
+}
+
+// selectOptions are values of a select with the current one selected
+type selectOptions struct {
+	Current string
+	Values  []string
+}
+
+func toFloat(value any) float64 {
+	switch v := value.(type) {
+	case int:
+		return float64(v)
+	case float64:
+		return v
+	default:
+		return 0
+	}
+}
+
+var cardinalAbbreviations = map[string]string{
+	"NORTH": "N", "NORTH_NORTHEAST": "NNE", "NORTHEAST": "NE", "EAST_NORTHEAST": "ENE",
+	"EAST": "E", "EAST_SOUTHEAST": "ESE", "SOUTHEAST": "SE", "SOUTH_SOUTHEAST": "SSE",
+	"SOUTH": "S", "SOUTH_SOUTHWEST": "SSW", "SOUTHWEST": "SW", "WEST_SOUTHWEST": "WSW",
+	"WEST": "W", "WEST_NORTHWEST": "WNW", "NORTHWEST": "NW", "NORTH_NORTHWEST": "NNW",
+}
+
+func formatWind(locale *Locale, weather *models.Weather) string {
+	wind := locale.number(weather.WindSpeed, 0) + " " + locale.unit("speed")
+	if direction, ok := cardinalAbbreviations[weather.WindCardinal]; ok {
+		wind += " " + direction
+	}
+	if weather.WindGust > weather.WindSpeed {
+		wind += ", " + locale.Text("wind.gusts") + " " + locale.number(weather.WindGust, 0) + " " + locale.unit("speed")
+	}
+
+	return wind
+}
+
+func formatPrecipitation(locale *Locale, weather *models.Weather) string {
+	precipitation := locale.number(float64(weather.PrecipitationProbability), 0) + "%"
+	if weather.PrecipitationType != "" {
+		precipitation += " " + strings.ToLower(strings.ReplaceAll(weather.PrecipitationType, "_", " "))
+	}
+	if weather.Precipitation > 0 {
+		precipitation += ", " + locale.number(weather.Precipitation, 2) + " " + locale.unit("precipitation")
+	}
+	if weather.ThunderstormProbability > 0 {
+		precipitation += ", " + locale.Text("precipitation.thunderstorm") + " " +
+			locale.number(float64(weather.ThunderstormProbability), 0) + "%"
+	}
+
+	return precipitation
+}
+
+// formatHistory summarizes last 24 hours, templates omit it when provider does not report history
+func formatHistory(locale *Locale, history models.WeatherHistory) string {
+	return locale.temperature(history.MaxTemperature, true) + " / " +
+		locale.temperature(history.MinTemperature, true) + ", " +
+		locale.printer.Sprintf("%+.1f°", history.TemperatureChange) + " " + locale.Text("history.change") + ", " +
+		locale.number(history.Precipitation, 2) + " " + locale.unit("precipitation")
+}
+
+// formatRule returns human-readable rule condition in locale language
+func formatRule(locale *Locale, rule models.AlertRule) string {
+	metric := locale.Text("metric." + rule.Metric)
+	if metric == "" {
+		metric = rule.Metric
+	}
+
+	switch models.AlertOperator(rule.Operator) {
+	case models.LessThan, models.GreaterThan:
+		return locale.Sprintf("rule."+rule.Operator, metric, rule.Value)
+	default:
+		return locale.Sprintf("rule.contains", metric, rule.Value)
+	}
+}
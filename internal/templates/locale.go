@@ -0,0 +1,113 @@
+package templates
+
+import (
+	"fmt"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+	"golang.org/x/text/number"
+	"sort"
+	"time"
+	"weather-subscriptions/internal/db/models"
+)
+
+// Locale renders emails in subscriber language with numbers formatted the way the language does
+// and values converted to subscriber units
+type Locale struct {
+	Language string
+	Units    models.Units
+	catalog  *catalog
+	fallback *catalog
+	printer  *message.Printer
+}
+
+// NewLocale returns locale of the language, unsupported language falls back to the default one
+func NewLocale(lang string, units models.Units) *Locale {
+	return current.locale(lang, units)
+}
+
+// SupportedLanguage reports whether emails can be rendered in the language
+func SupportedLanguage(lang string) bool {
+	_, ok := current.catalogs[lang]
+	return ok
+}
+
+// Languages returns supported languages in alphabetical order
+func Languages() []string {
+	languages := make([]string, 0, len(current.catalogs))
+	for lang := range current.catalogs {
+		languages = append(languages, lang)
+	}
+	sort.Strings(languages)
+
+	return languages
+}
+
+// Text returns string of the key in locale language, missing strings are taken from the default language
+func (l *Locale) Text(key string) string {
+	if text, ok := l.catalog.Texts[key]; ok {
+		return text
+	}
+
+	return l.fallback.Texts[key]
+}
+
+// Sprintf formats string of the key with locale number formatting
+func (l *Locale) Sprintf(key string, args ...any) string {
+	return l.printer.Sprintf(l.Text(key), args...)
+}
+
+// number formats value with at most precision fraction digits
+func (l *Locale) number(value float64, precision int) string {
+	return l.printer.Sprint(number.Decimal(value, number.MaxFractionDigits(precision)))
+}
+
+// temperature formats degrees with single fraction digit, unit is omitted when short is set
+func (l *Locale) temperature(degrees float64, short bool) string {
+	formatted := l.printer.Sprint(number.Decimal(degrees, number.MinFractionDigits(1), number.MaxFractionDigits(1)))
+	if short {
+		return formatted + "°"
+	}
+
+	return formatted + " " + l.unit("temperature")
+}
+
+// unit returns label of the quantity in locale units
+func (l *Locale) unit(quantity string) string {
+	return l.Text("unit." + quantity + "." + string(l.Units))
+}
+
+// date formats date as "Monday, 2 January", short form is "Mon, 2 Jan"
+func (l *Locale) date(date time.Time, short bool) string {
+	weekdays, months := l.catalog.Weekdays, l.catalog.Months
+	if short {
+		weekdays, months = l.catalog.ShortWeekdays, l.catalog.ShortMonths
+	}
+
+	return fmt.Sprintf("%s, %d %s", weekdays[date.Weekday()], date.Day(), months[date.Month()-1])
+}
+
+// catalog holds strings of a language, it is read from i18n/<language>.json
+type catalog struct {
+	Texts         map[string]string `json:"texts"`
+	Weekdays      [7]string         `json:"weekdays"`
+	ShortWeekdays [7]string         `json:"shortWeekdays"`
+	Months        [12]string        `json:"months"`
+	ShortMonths   [12]string        `json:"shortMonths"`
+}
+
+func (s *set) locale(lang string, units models.Units) *Locale {
+	if _, ok := s.catalogs[lang]; !ok {
+		lang = models.DefaultLanguage
+	}
+	if units != models.Imperial {
+		units = models.Metric
+	}
+
+	return &Locale{
+		Language: lang,
+		Units:    units,
+		catalog:  s.catalogs[lang],
+		fallback: s.catalogs[models.DefaultLanguage],
+		printer:  message.NewPrinter(language.Make(lang)),
+	}
+}
@@ -0,0 +1,186 @@
+package templates
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"weather-subscriptions/internal/db/models"
+)
+
+//go:embed files
+var embedded embed.FS
+
+// pages are rendered with the shared layout, files are relative to the templates directory
+var pages = map[string]string{
+	"weather":      "emails/weather.html",
+	"alert":        "emails/alert.html",
+	"verification": "emails/verification.html",
+	"digest":       "emails/digest.html",
+	"manage":       "pages/manage.html",
+	"deleted":      "pages/deleted.html",
+	"snooze":       "pages/snooze.html",
+}
+
+// set is parsed pages with catalogs of supported languages
+type set struct {
+	pages    map[string]*template.Template
+	catalogs map[string]*catalog
+}
+
+// current is used by all renders, it is replaced by Load
+var current = mustLoad(files())
+
+// Load reads templates from dir, files missing in dir are taken from the embedded templates.
+// A language is added by placing its catalog to i18n/<language>.json of dir
+func Load(dir string) error {
+	loaded, err := load(overlay{upper: os.DirFS(dir), lower: files()})
+	if err != nil {
+		return err
+	}
+	current = loaded
+
+	return nil
+}
+
+func files() fs.FS {
+	sub, err := fs.Sub(embedded, "files")
+	if err != nil {
+		panic(err)
+	}
+
+	return sub
+}
+
+func mustLoad(fsys fs.FS) *set {
+	loaded, err := load(fsys)
+	if err != nil {
+		panic(fmt.Sprintf("failed to load embedded templates: %v", err))
+	}
+
+	return loaded
+}
+
+func load(fsys fs.FS) (*set, error) {
+	catalogs, err := loadCatalogs(fsys)
+	if err != nil {
+		return nil, err
+	}
+	loaded := &set{
+		pages:    make(map[string]*template.Template, len(pages)),
+		catalogs: catalogs,
+	}
+
+	// functions are bound to subscriber locale on render, default locale only declares them for parsing
+	base, err := template.New("layout").
+		Funcs(funcs(loaded.locale(models.DefaultLanguage, models.Metric))).
+		ParseFS(fsys, "layout.html", "partials/*.html")
+	if err != nil {
+		return nil, err
+	}
+	for name, file := range pages {
+		page, err := base.Clone()
+		if err != nil {
+			return nil, err
+		}
+		loaded.pages[name], err = page.ParseFS(fsys, file)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return loaded, nil
+}
+
+func loadCatalogs(fsys fs.FS) (map[string]*catalog, error) {
+	files, err := fs.Glob(fsys, "i18n/*.json")
+	if err != nil {
+		return nil, err
+	}
+
+	catalogs := make(map[string]*catalog, len(files))
+	for _, file := range files {
+		content, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, err
+		}
+		var c catalog
+		err = json.Unmarshal(content, &c)
+		if err != nil {
+			return nil, fmt.Errorf("invalid catalog %s: %w", file, err)
+		}
+		catalogs[strings.TrimSuffix(path.Base(file), ".json")] = &c
+	}
+	if _, ok := catalogs[models.DefaultLanguage]; !ok {
+		return nil, errors.New("catalog of the default language is missing")
+	}
+
+	return catalogs, nil
+}
+
+// render executes the page layout with functions of the locale
+func (s *set) render(name string, locale *Locale, data any) (string, error) {
+	page, err := s.pages[name].Clone()
+	if err != nil {
+		return "", err
+	}
+
+	var body strings.Builder
+	err = page.Funcs(funcs(locale)).ExecuteTemplate(&body, "layout", data)
+	if err != nil {
+		return "", err
+	}
+
+	return body.String(), nil
+}
+
+// overlay reads files from upper file system falling back to lower one
+type overlay struct {
+	upper fs.FS
+	lower fs.FS
+}
+
+func (o overlay) Open(name string) (fs.File, error) {
+	file, err := o.upper.Open(name)
+	if err == nil || !errors.Is(err, fs.ErrNotExist) {
+		return file, err
+	}
+
+	return o.lower.Open(name)
+}
+
+// ReadDir merges entries of both file systems, so globs match files of either
+func (o overlay) ReadDir(name string) ([]fs.DirEntry, error) {
+	entries := make(map[string]fs.DirEntry)
+	found := false
+	for _, fsys := range []fs.FS{o.lower, o.upper} {
+		dirEntries, err := fs.ReadDir(fsys, name)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		found = true
+		for _, entry := range dirEntries {
+			entries[entry.Name()] = entry
+		}
+	}
+	if !found {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+
+	merged := make([]fs.DirEntry, 0, len(entries))
+	for _, entry := range entries {
+		merged = append(merged, entry)
+	}
+	sort.Slice(merged, func(i, j int) bool { return merged[i].Name() < merged[j].Name() })
+
+	return merged, nil
+}
@@ -2,32 +2,235 @@ package templates
 
 import (
 	"fmt"
-	"strconv"
+	"time"
 	"weather-subscriptions/internal/db/models"
 )
 
 const (
 	unsubscribeLinkTemplate = "%s/unsubscribe/%s"
 	subscribeLinkTemplate   = "%s/confirm/%s"
+	manageLinkTemplate      = "%s/manage/%s"
+	snoozeLinkTemplate      = "%s/manage/%s/snooze?days=%d"
+	// snoozeLinkDays is the snooze duration of links in emails and on the management page
+	snoozeLinkDays = 7
 )
 
+// links are subscription links shown by templates, empty links are omitted
+type links struct {
+	Confirm     string
+	Snooze      string
+	Unsubscribe string
+	Manage      string
+	Pause       string
+	Resume      string
+	Delete      string
+}
+
+// subscriptionLinks returns links of subscription emails
+func subscriptionLinks(frontendURL, code, manageCode string) links {
+	return links{
+		Snooze:      fmt.Sprintf(snoozeLinkTemplate, frontendURL, manageCode, snoozeLinkDays),
+		Unsubscribe: UnsubscribeLink(frontendURL, code),
+		Manage:      fmt.Sprintf(manageLinkTemplate, frontendURL, manageCode),
+	}
+}
+
+// UnsubscribeLink returns link which removes subscription of the unsubscribe code
+func UnsubscribeLink(frontendURL, code string) string {
+	return fmt.Sprintf(unsubscribeLinkTemplate, frontendURL, code)
+}
+
+type weatherEmail struct {
+	Links   links
+	Weather *models.Weather
+}
+
+// GetWeatherEmailBody renders the weather in locale language and units
 func GetWeatherEmailBody(
+	locale *Locale,
+	weather *models.Weather,
+	frontendURL, code, manageCode string,
+) (string, error) {
+	return current.render("weather", locale, weatherEmail{
+		Links:   subscriptionLinks(frontendURL, code, manageCode),
+		Weather: weather.InUnits(locale.Units),
+	})
+}
+
+type alertEmail struct {
+	Links   links
+	Weather *models.Weather
+	Rules   []models.AlertRule
+}
+
+// GetAlertEmailBody renders conditions of the triggered rules with the weather which triggered them
+func GetAlertEmailBody(
+	locale *Locale,
 	weather *models.Weather,
-	frontendURL, code string,
-) string {
-	return fmt.Sprintf(
-		weatherEmailTemplate,
-		strconv.FormatFloat(weather.Temperature, 'f', -1, 64),
-		strconv.Itoa(weather.Humidity),
-		weather.Description,
-		fmt.Sprintf(unsubscribeLinkTemplate, frontendURL, code),
-	)
-}
-
-func GetVerificationEmailTemplate(frontendURL, code string) string {
-	subscribeLink := fmt.Sprintf(subscribeLinkTemplate, frontendURL, code)
-	return fmt.Sprintf(
-		verificationEmailTemplate,
-		subscribeLink,
-	)
+	triggered []models.AlertRule,
+	frontendURL, code, manageCode string,
+) (string, error) {
+	emailLinks := subscriptionLinks(frontendURL, code, manageCode)
+	emailLinks.Snooze = ""
+
+	return current.render("alert", locale, alertEmail{
+		Links:   emailLinks,
+		Weather: weather.InUnits(locale.Units),
+		Rules:   triggered,
+	})
+}
+
+type verificationEmail struct {
+	Links links
+}
+
+func GetVerificationEmailTemplate(locale *Locale, frontendURL, code, manageCode string) (string, error) {
+	return current.render("verification", locale, verificationEmail{
+		Links: links{
+			Confirm: fmt.Sprintf(subscribeLinkTemplate, frontendURL, code),
+			Manage:  fmt.Sprintf(manageLinkTemplate, frontendURL, manageCode),
+		},
+	})
+}
+
+// ManagePage holds subscription details shown on the management page
+type ManagePage struct {
+	Email        string
+	City         string
+	Frequency    string
+	Timezone     string
+	DeliveryHour int
+	Confirmed    bool
+	Paused       bool
+	SnoozedUntil *time.Time
+	Units        string
+	Language     string
+}
+
+type managePage struct {
+	ManagePage
+	Links       links
+	Status      string
+	Stopped     bool
+	Frequencies []string
+	UnitSystems []string
+	Languages   []string
+}
+
+// GetManagePage renders the management page in subscriber language
+func GetManagePage(page ManagePage, frontendURL, code string) (string, error) {
+	locale := NewLocale(page.Language, models.Units(page.Units))
+	manageLink := fmt.Sprintf(manageLinkTemplate, frontendURL, code)
+
+	status := locale.Text("manage.active")
+	switch {
+	case !page.Confirmed:
+		status = locale.Text("manage.unconfirmed")
+	case page.Paused:
+		status = locale.Text("manage.paused")
+	case page.SnoozedUntil != nil:
+		status = locale.Sprintf("manage.snoozed", page.SnoozedUntil.UTC().Format("2 Jan 2006 15:04 MST"))
+	}
+
+	return current.render("manage", locale, managePage{
+		ManagePage: page,
+		Links: links{
+			Manage: manageLink,
+			Pause:  manageLink + "/pause",
+			Resume: manageLink + "/resume",
+			Snooze: fmt.Sprintf(snoozeLinkTemplate, frontendURL, code, snoozeLinkDays),
+			Delete: manageLink + "/delete",
+		},
+		Status:      status,
+		Stopped:     page.Paused || page.SnoozedUntil != nil,
+		Frequencies: models.SubscriptionTypeNames(),
+		UnitSystems: []string{string(models.Metric), string(models.Imperial)},
+		Languages:   Languages(),
+	})
+}
+
+type snoozePage struct {
+	Links   links
+	Message string
+}
+
+// GetSnoozePage renders confirmation of snoozing the subscription for days, the snooze is submitted
+// by its form, so links opened by mail scanners do not snooze
+func GetSnoozePage(lang, units, frontendURL, code string, days int) (string, error) {
+	locale := NewLocale(lang, models.Units(units))
+
+	return current.render("snooze", locale, snoozePage{
+		Links: links{
+			Snooze: fmt.Sprintf(snoozeLinkTemplate, frontendURL, code, days),
+			Manage: fmt.Sprintf(manageLinkTemplate, frontendURL, code),
+		},
+		Message: locale.Sprintf("snooze.message", days),
+	})
+}
+
+// GetSubscriptionDeletedPage renders confirmation of the deletion in subscriber language
+func GetSubscriptionDeletedPage(lang string) (string, error) {
+	return current.render("deleted", NewLocale(lang, models.Metric), nil)
+}
+
+const (
+	digestHourStep = 3
+	digestDays     = 3
+)
+
+// clock returns the current time of digests, tests fix it to render them reproducibly
+var clock = time.Now
+
+type digestEmail struct {
+	Links links
+	City  string
+	Today models.ForecastDay
+	Hours []models.ForecastHour
+	Days  []models.ForecastDay
+}
+
+// GetDailyDigestBody renders forecast of the day in location with hourly breakdown and outlook of next days
+// in locale language and units
+func GetDailyDigestBody(
+	locale *Locale,
+	forecast *models.Forecast,
+	cityName string,
+	location *time.Location,
+	frontendURL, code, manageCode string,
+) (string, error) {
+	forecast = forecast.InUnits(locale.Units)
+	now := clock().In(location)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	var days []models.ForecastDay
+	for _, day := range forecast.Days {
+		if !day.Date.Before(today) && len(days) < digestDays {
+			days = append(days, day)
+		}
+	}
+	todayForecast := models.ForecastDay{Date: today}
+	if len(days) > 0 {
+		todayForecast, days = days[0], days[1:]
+	}
+
+	var hours []models.ForecastHour
+	shown := 0
+	for _, hour := range forecast.Hours {
+		if hour.Time.Before(now.Truncate(time.Hour)) {
+			continue
+		}
+		if shown%digestHourStep == 0 {
+			hour.Time = hour.Time.In(location)
+			hours = append(hours, hour)
+		}
+		shown++
+	}
+
+	return current.render("digest", locale, digestEmail{
+		Links: subscriptionLinks(frontendURL, code, manageCode),
+		City:  cityName,
+		Today: todayForecast,
+		Hours: hours,
+		Days:  days,
+	})
 }
@@ -0,0 +1,170 @@
+package templates
+
+import (
+	"flag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"weather-subscriptions/internal/db/models"
+)
+
+// update rewrites golden files with the rendered output: go test ./internal/templates -update
+var update = flag.Bool("update", false, "update golden files")
+
+const (
+	testFrontendURL = "https://weather.example.com"
+	testCode        = "unsub-code"
+	testManageCode  = "manage-code"
+)
+
+// testNow is the fixed current time of digests
+var testNow = time.Date(2025, time.June, 14, 7, 30, 0, 0, time.UTC)
+
+func testWeather() *models.Weather {
+	return &models.Weather{
+		Temperature:              21.4,
+		Humidity:                 63,
+		Description:              "Light rain <b>& wind</b>",
+		WindSpeed:                15,
+		WindGust:                 31,
+		WindCardinal:             "WEST_SOUTHWEST",
+		PrecipitationProbability: 55,
+		PrecipitationType:        "RAIN",
+		Precipitation:            0.4,
+		ThunderstormProbability:  10,
+		UVIndex:                  4,
+		Pressure:                 1012.6,
+		Visibility:               16,
+		CloudCover:               88,
+		History: models.WeatherHistory{
+			TemperatureChange: -1.2,
+			MaxTemperature:    24.1,
+			MinTemperature:    13.2,
+			Precipitation:     2.3,
+			Available:         true,
+		},
+		Units: string(models.Metric),
+	}
+}
+
+func testForecast() *models.Forecast {
+	forecast := &models.Forecast{Units: string(models.Metric)}
+	for day := range 4 {
+		forecast.Days = append(forecast.Days, models.ForecastDay{
+			Date:                     time.Date(2025, time.June, 14+day, 0, 0, 0, 0, time.UTC),
+			MaxTemperature:           24.1 + float64(day),
+			MinTemperature:           13.2 + float64(day),
+			PrecipitationProbability: 10 * day,
+			Description:              "Partly cloudy",
+		})
+	}
+	for hour := range 12 {
+		forecast.Hours = append(forecast.Hours, models.ForecastHour{
+			Time:                     time.Date(2025, time.June, 14, 6+hour, 0, 0, 0, time.UTC),
+			Temperature:              15.5 + float64(hour),
+			Humidity:                 70 - hour,
+			PrecipitationProbability: 5 * hour,
+			Description:              "Clear <sky>",
+		})
+	}
+
+	return forecast
+}
+
+func TestTemplates(t *testing.T) {
+	defer func(previous func() time.Time) { clock = previous }(clock)
+	clock = func() time.Time { return testNow }
+	kyiv, err := time.LoadLocation("Europe/Kyiv")
+	require.NoError(t, err)
+	snoozedUntil := time.Date(2025, time.June, 21, 7, 30, 0, 0, time.UTC)
+
+	renders := map[string]func(lang string) (string, error){
+		"weather": func(lang string) (string, error) {
+			return GetWeatherEmailBody(NewLocale(lang, models.Metric), testWeather(), testFrontendURL, testCode, testManageCode)
+		},
+		"weather_imperial": func(lang string) (string, error) {
+			return GetWeatherEmailBody(NewLocale(lang, models.Imperial), testWeather(), testFrontendURL, testCode, testManageCode)
+		},
+		"alert": func(lang string) (string, error) {
+			rules := []models.AlertRule{
+				{Metric: string(models.TemperatureMetric), Operator: string(models.GreaterThan), Value: "20"},
+				{Metric: string(models.DescriptionMetric), Operator: string(models.Contains), Value: "<rain>"},
+			}
+			return GetAlertEmailBody(NewLocale(lang, models.Metric), testWeather(), rules, testFrontendURL, testCode, testManageCode)
+		},
+		"digest": func(lang string) (string, error) {
+			return GetDailyDigestBody(
+				NewLocale(lang, models.Metric), testForecast(), "Kyiv", kyiv, testFrontendURL, testCode, testManageCode,
+			)
+		},
+		"verification": func(lang string) (string, error) {
+			return GetVerificationEmailTemplate(NewLocale(lang, models.Metric), testFrontendURL, testCode, testManageCode)
+		},
+		"manage": func(lang string) (string, error) {
+			return GetManagePage(ManagePage{
+				Email:        "user@example.com",
+				City:         "Kyiv",
+				Frequency:    "daily",
+				Timezone:     "Europe/Kyiv",
+				DeliveryHour: 8,
+				Confirmed:    true,
+				SnoozedUntil: &snoozedUntil,
+				Units:        string(models.Metric),
+				Language:     lang,
+			}, testFrontendURL, testManageCode)
+		},
+		"manage_unconfirmed": func(lang string) (string, error) {
+			return GetManagePage(ManagePage{
+				Email:     "<script>@example.com",
+				City:      "Kyiv",
+				Frequency: "hourly",
+				Timezone:  "UTC",
+				Units:     string(models.Imperial),
+				Language:  lang,
+			}, testFrontendURL, testManageCode)
+		},
+		"snooze": func(lang string) (string, error) {
+			return GetSnoozePage(lang, string(models.Metric), testFrontendURL, testManageCode, snoozeLinkDays)
+		},
+		"deleted": func(lang string) (string, error) {
+			return GetSubscriptionDeletedPage(lang)
+		},
+	}
+
+	for name, render := range renders {
+		for _, lang := range []string{"en", "uk"} {
+			t.Run(name+"_"+lang, func(t *testing.T) {
+				got, err := render(lang)
+				require.NoError(t, err)
+
+				golden := filepath.Join("testdata", name+"_"+lang+".golden")
+				if *update {
+					require.NoError(t, os.WriteFile(golden, []byte(got), 0o644))
+				}
+				want, err := os.ReadFile(golden)
+				require.NoError(t, err, "run with -update to create golden files")
+				assert.Equal(t, string(want), got)
+			})
+		}
+	}
+}
+
+func TestTemplatesEscapeValues(t *testing.T) {
+	body, err := GetWeatherEmailBody(NewLocale("en", models.Metric), testWeather(), testFrontendURL, testCode, testManageCode)
+	require.NoError(t, err)
+
+	assert.Contains(t, body, "Light rain &lt;b&gt;&amp; wind&lt;/b&gt;")
+	assert.NotContains(t, body, "<b>")
+}
+
+func TestUnsupportedLanguageFallsBackToDefault(t *testing.T) {
+	want, err := GetSubscriptionDeletedPage(models.DefaultLanguage)
+	require.NoError(t, err)
+
+	got, err := GetSubscriptionDeletedPage("xx")
+	require.NoError(t, err)
+	assert.Equal(t, want, got)
+}
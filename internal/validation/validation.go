@@ -0,0 +1,128 @@
+package validation
+
+import (
+	"fmt"
+	"github.com/go-playground/validator"
+	"math"
+	"reflect"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+	"weather-subscriptions/internal/db/models"
+)
+
+const (
+	cityMinLength = 2
+	cityMaxLength = 100
+	maxLatitude   = 90
+	maxLongitude  = 180
+)
+
+// validate is shared by all requests, it is safe for concurrent use
+var validate = newValidator()
+
+func newValidator() *validator.Validate {
+	v := validator.New()
+	// errors name fields the way requests do
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+	mustRegister(v, "frequency", func(fl validator.FieldLevel) bool {
+		return models.SubscriptionType(fl.Field().String()).Supported()
+	})
+	mustRegister(v, "city", func(fl validator.FieldLevel) bool {
+		return ValidCity(fl.Field().String())
+	})
+	mustRegister(v, "lat", func(fl validator.FieldLevel) bool {
+		return coordinateInRange(fl.Field(), maxLatitude)
+	})
+	mustRegister(v, "lon", func(fl validator.FieldLevel) bool {
+		return coordinateInRange(fl.Field(), maxLongitude)
+	})
+
+	return v
+}
+
+func mustRegister(v *validator.Validate, tag string, fn validator.Func) {
+	if err := v.RegisterValidation(tag, fn); err != nil {
+		panic(fmt.Sprintf("failed to register %s validation: %v", tag, err))
+	}
+}
+
+// Struct validates request by its validate tags, it returns validator.ValidationErrors for invalid fields
+func Struct(request any) error {
+	return validate.Struct(request)
+}
+
+// coordinateInRange reports whether coordinate is between -limit and limit. Missing coordinate
+// is in range, it is checked by required rules, since omitempty does not follow them for nil pointers
+func coordinateInRange(field reflect.Value, limit float64) bool {
+	if field.Kind() == reflect.Ptr {
+		if field.IsNil() {
+			return true
+		}
+		field = field.Elem()
+	}
+
+	return math.Abs(field.Float()) <= limit
+}
+
+// ValidCity reports whether name looks like a city name: 2-100 characters of letters, digits, spaces,
+// hyphens, apostrophes, dots and commas with at least one letter
+func ValidCity(name string) bool {
+	name = strings.TrimSpace(name)
+	length := utf8.RuneCountInString(name)
+	if length < cityMinLength || length > cityMaxLength {
+		return false
+	}
+
+	letters := 0
+	for _, r := range name {
+		switch {
+		case unicode.IsLetter(r):
+			letters++
+		case unicode.IsMark(r), unicode.IsDigit(r), r == ' ', r == '-', r == '\'', r == '’', r == '.', r == ',':
+		default:
+			return false
+		}
+	}
+
+	return letters > 0
+}
+
+// Message returns human-readable reason of the field error
+func Message(fieldError validator.FieldError) string {
+	switch fieldError.Tag() {
+	case "required":
+		return "is required"
+	case "email":
+		return "must be a valid email address"
+	case "oneof":
+		return "must be one of: " + strings.ReplaceAll(fieldError.Param(), " ", ", ")
+	case "min":
+		return "must be at least " + fieldError.Param()
+	case "max":
+		return "must be at most " + fieldError.Param()
+	case "required_with":
+		return "is required with " + strings.ToLower(fieldError.Param())
+	case "required_without":
+		return "is required unless " + strings.ToLower(fieldError.Param()) + " is given"
+	case "lat":
+		return fmt.Sprintf("must be a latitude between -%d and %d", maxLatitude, maxLatitude)
+	case "lon":
+		return fmt.Sprintf("must be a longitude between -%d and %d", maxLongitude, maxLongitude)
+	case "frequency":
+		return "must be one of: " + strings.Join(models.SubscriptionTypeNames(), ", ")
+	case "city":
+		return fmt.Sprintf(
+			"must be %d-%d characters of letters, digits, spaces, hyphens, apostrophes, dots or commas",
+			cityMinLength, cityMaxLength,
+		)
+	default:
+		return fmt.Sprintf("failed on the '%s' rule", fieldError.Tag())
+	}
+}
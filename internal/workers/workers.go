@@ -0,0 +1,25 @@
+package workers
+
+import "sync"
+
+// Run calls fn for every item using at most the given number of goroutines and waits for all calls
+func Run[T any](workers int, items []T, fn func(item T)) {
+	workers = max(1, min(workers, len(items)))
+	jobs := make(chan T)
+	wg := sync.WaitGroup{}
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for item := range jobs {
+				fn(item)
+			}
+		}()
+	}
+
+	for _, item := range items {
+		jobs <- item
+	}
+	close(jobs)
+	wg.Wait()
+}